@@ -2,39 +2,287 @@ package Workerpool
 
 import (
 	"container/heap"
-	"fmt"
+	"context"
+	"errors"
+	"sync"
+	"time"
 )
 
+// ErrNoJob is the error of a Request which was created without a job
+var ErrNoJob = errors.New("Workerpool: request has no job")
+
 // Balancer has a Pool of workers and a channel to pass
 // workers through when they are finished a task
 type Balancer struct {
 	Pool 	*Pool
 	Done 	chan *Worker
+	Scaler 	*Scaler		// optional, keeps the Pool at a fixed size when nil
+	Strategy 	Strategy	// how workers are picked, defaults to LeastLoaded
+	Aging 	time.Duration	// waiting this long is worth a priority level, 0 means no aging
+	Metrics 	Metrics		// where to report what's going on, nothing is reported when nil
+	Steal 	bool			// let idle workers take queued requests from busy ones
+	Limit 	*RateLimit		// how fast requests can be dispatched, no limit when nil
+	Clock 	Clock			// defaults to the real time
+	Hooks 	*Hooks			// per worker state, see WorkerState
+	Fair 	*Fair			// share the workers between tenants, first come first served when nil
+	Types 	map[string]Isolation	// bulkheads and circuit breakers by job type, see WithType
+	Tracer 	Tracer			// follows every request through, nothing is traced when nil
+	Timeout time.Duration	// for the requests without WithTimeout, no timeout when 0
+	Grace 	time.Duration	// how long past its timeout a job gets before its worker is poisoned, defaults to a second
+	Mode 	QueueMode		// where requests wait for a worker, defaults to PerWorker
+	Depth 	int				// requests queued or running per worker, defaults to 30, or 2 for Hybrid
+	KeyBacklog 	int			// requests a key can have waiting behind the one that's out, more are rejected, defaults to 30
+
+	seq 	uint64
+	start 	time.Time
+	queued 	int				// requests waiting in the worker queues
+	retries chan *task		// failed tasks coming back after their backoff
+	backoff int				// failed tasks waiting out their backoff
+	limiter *limiter		// tasks held back by the Limit
+	wake 	<-chan time.Time // fires when the limiter has tokens again
+	keys 	map[string][]*task // requests waiting on another with the same key
+	keyed 	int
+	fair 	*fair			// tasks waiting for their turn under Fair
+	isolated 	map[string]*isolated	// the state of the Types
+	bulkheaded 	int
+	controlOnce sync.Once
+	calls 		chan func()		// Pause, Resume, Inspect and Cancel, see control
+	paused 		bool
+	alarm 		time.Time			// the earliest deadline of a running job, see watch
+	bark 		<-chan time.Time	// fires at the alarm
+	shared 		queue				// requests waiting for a worker with room, see Mode
+}
+
+// this initializes the channels the Balance loop listens on and hands
+// the Hooks to the workers, the rest of the Balancer is fine with its zero value
+func (b *Balancer) lazyInit() {
+	if b.retries == nil {
+		b.retries = make(chan *task)
+		for _, w := range *b.Pool {
+			w.hooks = b.Hooks
+		}
+	}
 }
 
-// Balance takes in a channel of requests and distrubutes them
+// Balance takes in a channel of requests and distrubutes them. Once requests
+// is closed and every request it took in is done it retires the workers and
+// returns
 func (b *Balancer) Balance(requests <-chan Request) {
+	b.lazyInit()
+
+	var tick <-chan time.Time
+	if b.Scaler != nil {
+		tick = b.clock().After(b.Scaler.interval())
+	}
+
 	for {
+		// stop taking requests while every worker has a full queue,
+		// they wait in the channel until somebody is done
+		in := requests
+		if b.full() {
+			in = nil
+		}
+
 		select {
-		case request := <-requests:
+		case request, ok := <-in:
+			if !ok {
+				requests = nil
+				break
+			}
 			b.dispatch(request)
-			fmt.Println(b.Pool)
 		case worker := <-b.Done:
-			b.complete(worker)
+			// a poisoned worker which finished after all is gone already
+			if !worker.poisoned {
+				b.complete(worker)
+			}
+		case t := <-b.retries:
+			b.retried(t)
+		case <-b.wake:
+			b.release()
+		case f := <-b.control():
+			f()
+		case now := <-tick:
+			b.scale(now)
+			tick = b.clock().After(b.Scaler.interval())
+		case now := <-b.bark:
+			b.expire(now)
+		}
+
+		if requests == nil && b.drained() {
+			b.stop()
+			return
 		}
 	}
 }
 
-// dispatch distrubutes the requests
+// drained tells whether every request the Balancer took in is done
+func (b *Balancer) drained() bool {
+	if b.waiting() > 0 || b.backoff > 0 {
+		return false
+	}
+	for _, w := range *b.Pool {
+		if w.pending > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// stop retires all the workers
+func (b *Balancer) stop() {
+	for _, w := range *b.Pool {
+		close(w.quit)
+		b.metrics().WorkerRemoved(w.id)
+	}
+	*b.Pool = (*b.Pool)[:0]
+}
+
+// full tells whether the Balancer should stop taking requests. Only the worker
+// queues and the shared queue count, up to depth requests per worker wait in
+// the shared queue. The requests held back by Fair, the RateLimit, a key or
+// a bulkhead have limits of their own, one tenant, key or type over it
+// shouldn't keep the others out
+func (b *Balancer) full() bool {
+	return b.Pool.Len() == 0 || (b.Mode == PerWorker && (*b.Pool)[0].pending >= b.depth()) ||
+		b.shared.Len() >= b.depth()*b.Pool.Len()
+}
+
+// waiting returns the number of tasks which aren't in a worker queue yet
+func (b *Balancer) waiting() int {
+	return b.held() + b.keyed + b.bulkheaded + b.fair.queued() + b.shared.Len()
+}
+
+func (b *Balancer) clock() Clock {
+	if b.Clock == nil {
+		return realClock{}
+	}
+	return b.Clock
+}
+
+// dispatch distrubutes the requests, the request is queued on the worker
+// and runs once the worker gets to it
 func (b *Balancer) dispatch(request Request) {
-	w := heap.Pop(b.Pool).(*Worker)
-	w.requests <- request
+	if request.job == nil {
+		b.reject(request, "no job", ErrNoJob)
+		return
+	}
+	if request.ctx == nil {
+		request.ctx = context.Background()
+	}
+
+	now := b.clock().Now()
+	if b.start.IsZero() {
+		b.start = now
+	}
+	if request.timeout == 0 {
+		request.timeout = b.Timeout
+	}
+	b.seq++
+	t := &task{
+		Request: 	request,
+		seq: 		b.seq,
+		rank: 		rank(request.priority, now.Sub(b.start), b.Aging),
+		enqueued: 	now,
+	}
+	b.trace(t, now)
+	b.serialize(t)
+}
+
+// assign puts t in the queue of w
+func (b *Balancer) assign(w *Worker, t *task) {
+	b.event(t, "workerpool.dispatch", "workerpool.worker", w.id)
+	b.phase(t, "workerpool.queue")
+	heap.Push(&w.queue, t)
 	w.pending += 1
-	heap.Push(b.Pool, w)
+	heap.Fix(b.Pool, w.index)
+	b.queued++
+	b.feed(w)
+	if b.Steal && w.queue.Len() > 0 {
+		b.feedIdle()
+	}
+	b.report(w)
+}
+
+// reject fails a request without running it. The result is sent from its own
+// goroutine so a caller who isn't listening yet can't hold up the Balancer
+func (b *Balancer) reject(request Request, reason string, err error) {
+	b.metrics().TaskRejected(reason)
+	if request.result == nil {
+		return
+	}
+	go func() {
+		request.result <- Result{Err: err}
+	}()
+}
+
+func (b *Balancer) strategy() Strategy {
+	if b.Strategy == nil {
+		return LeastLoaded{}
+	}
+	return b.Strategy
+}
+
+// feed hands an idle worker the highest ranked task in its queue, or one
+// stolen from another worker when its own queue is empty
+func (b *Balancer) feed(w *Worker) {
+	if w.running != nil || b.paused {
+		return
+	}
+	if w.queue.Len() == 0 && !(b.Steal && b.steal(w)) {
+		return
+	}
+	w.running = heap.Pop(&w.queue).(*task)
+	b.queued--
+	w.running.tries++
+	b.phase(w.running, "")
+	b.watch(w.running)
+	w.requests <- w.running
+}
+
+func (b *Balancer) metrics() Metrics {
+	if b.Metrics == nil {
+		return nopMetrics{}
+	}
+	return b.Metrics
+}
+
+// report sends the current load of w and of the whole Pool to the Metrics
+func (b *Balancer) report(w *Worker) {
+	m := b.metrics()
+	m.QueueDepth(b.queued + b.waiting())
+	m.WorkerPending(w.id, w.pending)
 }
 
 // complete updates the worker Pool when a request is complete
 func (b *Balancer) complete(worker *Worker) {
+	t := worker.running
+	if t.panicked {
+		b.metrics().TaskPanicked()
+	}
+	b.metrics().TaskDone(b.clock().Now().Sub(t.enqueued)-t.elapsed, t.elapsed)
+	worker.observe(t.elapsed)
+	b.settle(t, t.elapsed)
+	b.outcome(t, t.ran, t.failed, t.again)
+	b.event(t, "workerpool.complete", "workerpool.worker", worker.id, "workerpool.retry", t.again)
+	worker.running = nil
+	if t.again {
+		b.phase(t, "workerpool.backoff")
+		b.retry(t)
+	} else {
+		b.untrace(t, t.err)
+		if t.key != "" {
+			b.unblock(t.key)
+		}
+	}
 	worker.pending -= 1
+	if worker.pending == 0 {
+		worker.idle = b.clock().Now()
+	}
 	heap.Fix(b.Pool, worker.index)
-}
\ No newline at end of file
+	if t.unhealthy {
+		worker = b.replace(worker)
+	}
+	b.feed(worker)
+	b.report(worker)
+	b.deal()
+}
@@ -0,0 +1,133 @@
+package Workerpool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startBalancer runs b on a Pool of workers until the test is over, then
+// closes requests so it retires them
+func startBalancer(t *testing.T, workers int, b *Balancer) chan Request {
+	b.Done = make(chan *Worker)
+	b.Pool = New(workers, b.Done)
+	requests := make(chan Request)
+	go b.Balance(requests)
+	t.Cleanup(func() { close(requests) })
+	return requests
+}
+
+func TestNilResult(t *testing.T) {
+	requests := startBalancer(t, 1, &Balancer{})
+	ran := make(chan struct{}, 1)
+	requests <- NewRequest(func(context.Context) (int, error) {
+		ran <- struct{}{}
+		return 1, nil
+	}, nil)
+	<-ran
+
+	// the worker isn't stuck on the result nobody is waiting for
+	result := make(chan Result, 1)
+	requests <- NewRequest(ok, result)
+	select {
+	case r := <-result:
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the worker hung after a request without a result channel")
+	}
+}
+
+func TestBalanceReturns(t *testing.T) {
+	var stops int32
+	done := make(chan *Worker)
+	requests := make(chan Request)
+	b := &Balancer{Pool: New(2, done), Done: done, Hooks: &Hooks{
+		OnStart: func(id int) (interface{}, error) { return nil, nil },
+		OnStop: func(int, interface{}) { atomic.AddInt32(&stops, 1) },
+	}}
+	returned := make(chan struct{})
+	go func() {
+		b.Balance(requests)
+		close(returned)
+	}()
+
+	// the request still runs even though requests is closed right after
+	result := make(chan Result, 1)
+	requests <- NewRequest(func(context.Context) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return 1, nil
+	}, result)
+	close(requests)
+	<-returned
+	if r := <-result; r.Value != 1 {
+		t.Errorf("got %+v", r)
+	}
+	if b.Pool.Len() != 0 {
+		t.Errorf("got %d workers left", b.Pool.Len())
+	}
+	// the workers stop on their own goroutines
+	for i := 0; i < 100 && atomic.LoadInt32(&stops) != 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&stops); n != 1 {
+		t.Errorf("got %d workers stopped, want the one which started", n)
+	}
+}
+
+func TestBalanceDrains(t *testing.T) {
+	slow := func(context.Context) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		return 1, nil
+	}
+	for _, c := range []struct {
+		name 	string
+		b 		*Balancer
+		opts 	[]RequestOption
+		job 	func() Job
+	}{
+		// the first attempt fails, the second waits out its backoff
+		{"backoff", &Balancer{}, []RequestOption{WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: 20 * time.Millisecond})}, func() Job {
+			return failing(1, errTemporary)
+		}},
+		// all but the first wait for the one before them
+		{"key", &Balancer{}, []RequestOption{WithKey("k")}, func() Job { return slow }},
+		// all but the first wait for a token
+		{"limit", &Balancer{Limit: &RateLimit{Pool: Rate{PerSecond: 50, Burst: 1}}}, nil, func() Job { return ok }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			done := make(chan *Worker)
+			c.b.Pool, c.b.Done = New(2, done), done
+			requests := make(chan Request)
+			returned := make(chan struct{})
+			go func() {
+				c.b.Balance(requests)
+				close(returned)
+			}()
+
+			result := make(chan Result, 3)
+			for i := 0; i < 3; i++ {
+				requests <- NewRequest(c.job(), result, c.opts...)
+			}
+			close(requests)
+			select {
+			case <-returned:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Balance didn't return")
+			}
+			// every result is in by the time it returned
+			for i := 0; i < 3; i++ {
+				select {
+				case r := <-result:
+					if r.Err != nil {
+						t.Errorf("got %+v", r)
+					}
+				default:
+					t.Fatalf("got %d results, want 3", i)
+				}
+			}
+		})
+	}
+}
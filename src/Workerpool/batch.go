@@ -0,0 +1,126 @@
+package Workerpool
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// SubmitAll sends a Request for every job to requests, and returns the channels
+// their results come back on in the same order as the jobs
+func SubmitAll(requests chan<- Request, jobs []Job, opts ...RequestOption) []<-chan Result {
+	results := make([]<-chan Result, len(jobs))
+	for i, job := range jobs {
+		result := make(chan Result, 1)
+		requests <- NewRequest(job, result, opts...)
+		results[i] = result
+	}
+	return results
+}
+
+// WaitAll waits for every result and returns them in the same order
+func WaitAll(results []<-chan Result) []Result {
+	all := make([]Result, len(results))
+	for i, result := range results {
+		all[i] = <-result
+	}
+	return all
+}
+
+// WaitAny waits for the first result to come back and returns it along with the
+// index of its channel, or -1 if there are no channels to wait on
+func WaitAny(results []<-chan Result) (int, Result) {
+	if len(results) == 0 {
+		return -1, Result{}
+	}
+	cases := make([]reflect.SelectCase, len(results))
+	for i, result := range results {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(result)}
+	}
+	i, v, _ := reflect.Select(cases)
+	return i, v.Interface().(Result)
+}
+
+// Group runs jobs on the pool and waits for all of them, the first one to fail
+// cancels the context of the others, just like errgroup
+type Group struct {
+	requests 	chan<- Request
+	opts 		[]RequestOption
+	parent 		context.Context
+	ctx 		context.Context
+	cancel 		func()
+	skipped 	int32		// a job wasn't submitted as the Group was canceled
+	wg 			sync.WaitGroup
+	once 		sync.Once
+	err 		error
+}
+
+// NewGroup returns a Group submitting to requests, and the context its jobs run
+// with which is canceled when a job fails or Wait returns. opts apply to every
+// Request of the Group
+func NewGroup(ctx context.Context, requests chan<- Request, opts ...RequestOption) (*Group, context.Context) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{requests: requests, opts: opts, parent: parent, ctx: ctx, cancel: cancel}, ctx
+}
+
+// Go submits job, once the Group has been canceled nothing gets submitted
+func (g *Group) Go(job Job, opts ...RequestOption) {
+	if g.ctx.Err() != nil {
+		atomic.StoreInt32(&g.skipped, 1)
+		return
+	}
+	result := make(chan Result, 1)
+	all := make([]RequestOption, 0, len(g.opts)+len(opts)+1)
+	all = append(append(append(all, g.opts...), opts...), WithContext(g.ctx))
+	select {
+	case g.requests <- NewRequest(job, result, all...):
+	case <-g.ctx.Done():
+		atomic.StoreInt32(&g.skipped, 1)
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if res := <-result; res.Err != nil {
+			g.once.Do(func() {
+				g.err = res.Err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for every job submitted and returns the first error. When the
+// parent context is done, or jobs were left out as the Group was canceled,
+// that's an error too
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	err := g.err
+	if err == nil && (atomic.LoadInt32(&g.skipped) != 0 || g.parent.Err() != nil) {
+		err = g.ctx.Err()
+	}
+	g.cancel()
+	return err
+}
+
+// Map runs fn for every input on the pool and returns the values in the same
+// order as the inputs. The first error cancels the rest and is returned
+func Map(ctx context.Context, requests chan<- Request, inputs []interface{}, fn func(ctx context.Context, input interface{}) (int, error), opts ...RequestOption) ([]int, error) {
+	values := make([]int, len(inputs))
+	g, _ := NewGroup(ctx, requests, opts...)
+	for i, input := range inputs {
+		i, input := i, input
+		g.Go(func(ctx context.Context) (int, error) {
+			v, err := fn(ctx, input)
+			values[i] = v
+			return v, err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
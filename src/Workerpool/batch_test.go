@@ -0,0 +1,125 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubmitAll(t *testing.T) {
+	requests := startBalancer(t, 4, &Balancer{})
+
+	jobs := make([]Job, 10)
+	for i := range jobs {
+		i := i
+		jobs[i] = func(context.Context) (int, error) {
+			// finish in reverse so the order has to be put back together
+			time.Sleep(time.Duration(10-i) * time.Millisecond)
+			return i, nil
+		}
+	}
+	for i, r := range WaitAll(SubmitAll(requests, jobs)) {
+		if r.Value != i {
+			t.Errorf("result %d is %d", i, r.Value)
+		}
+	}
+
+	i, r := WaitAny(SubmitAll(requests, jobs))
+	if i != r.Value {
+		t.Errorf("result of job %d came back on channel %d", r.Value, i)
+	}
+	if i, _ := WaitAny(nil); i != -1 {
+		t.Errorf("got %d waiting on nothing, want -1", i)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	errFirst := errors.New("first")
+	g, ctx := NewGroup(context.Background(), requests)
+	canceled := make(chan struct{})
+	g.Go(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(canceled)
+		return 0, ctx.Err()
+	})
+	g.Go(func(context.Context) (int, error) {
+		return 0, errFirst
+	})
+	if err := g.Wait(); err != errFirst {
+		t.Errorf("got %v, want the first error", err)
+	}
+	<-canceled
+	if ctx.Err() == nil {
+		t.Errorf("context wasn't canceled")
+	}
+
+	// nothing gets submitted once the group is canceled
+	g.Go(func(context.Context) (int, error) {
+		t.Error("ran after the group was canceled")
+		return 0, nil
+	})
+}
+
+func TestMap(t *testing.T) {
+	requests := startBalancer(t, 3, &Balancer{})
+
+	inputs := []interface{}{"a", "bb", "ccc", "dddd"}
+	values, err := Map(context.Background(), requests, inputs, func(_ context.Context, in interface{}) (int, error) {
+		return len(in.(string)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if v != i+1 {
+			t.Errorf("value %d is %d, want %d", i, v, i+1)
+		}
+	}
+
+	errOdd := errors.New("odd")
+	_, err = Map(context.Background(), requests, []interface{}{2, 4, 5, 6}, func(_ context.Context, in interface{}) (int, error) {
+		if in.(int)%2 == 1 {
+			return 0, errOdd
+		}
+		return in.(int), nil
+	})
+	if err != errOdd {
+		t.Errorf("got %v, want errOdd", err)
+	}
+}
+
+func TestGroupCanceled(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	// nothing runs on a canceled context, which Wait reports
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	values, err := Map(ctx, requests, []interface{}{1, 2, 3}, func(_ context.Context, in interface{}) (int, error) {
+		return in.(int), nil
+	})
+	if err != context.Canceled || values != nil {
+		t.Errorf("got %v, %v on a canceled context", values, err)
+	}
+
+	// options with room to spare are shared by every Go
+	opts := make([]RequestOption, 1, 4)
+	opts[0] = WithPriority(1)
+	g, _ := NewGroup(context.Background(), requests, opts...)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g.Go(func(context.Context) (int, error) { return i, nil }, WithKey(strconv.Itoa(i)))
+		}(i)
+	}
+	wg.Wait()
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,309 @@
+package Workerpool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is the error of a request whose type has its circuit
+// breaker open
+var ErrCircuitOpen = errors.New("Workerpool: circuit breaker is open")
+
+// ErrBulkheadFull is the error of a request whose type already has
+// Isolation.MaxWaiting requests waiting at the bulkhead
+var ErrBulkheadFull = errors.New("Workerpool: too many requests waiting at the bulkhead")
+
+// Isolation keeps the jobs of one type (see WithType) from taking the whole
+// Pool down with them when what they depend on is failing or slow
+type Isolation struct {
+	// MaxRunning is the bulkhead, how many requests of the type can be out
+	// at once, queued on a worker or running. The others wait their turn in
+	// the Balancer. No limit when 0
+	MaxRunning 	int
+	// MaxWaiting is how many requests can wait their turn at the bulkhead,
+	// more are rejected. Defaults to 30
+	MaxWaiting 	int
+	// Breaker stops running jobs of the type once too many of them fail
+	Breaker 	*Breaker
+}
+
+func (i Isolation) maxWaiting() int {
+	if i.MaxWaiting <= 0 {
+		return int(defaultSize)
+	}
+	return i.MaxWaiting
+}
+
+// BreakerState is where a circuit breaker is at
+type BreakerState int
+
+const (
+	// Closed lets every request through
+	Closed BreakerState = iota
+	// Open rejects every request with ErrCircuitOpen
+	Open
+	// HalfOpen lets a few trial requests through to find out whether things
+	// got better, the others are rejected
+	HalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker opens when the failure rate of the last Window jobs of a type goes
+// over FailureRate. Once it was open for OpenFor it goes half-open, if the
+// Trials that go through then all succeed it closes and if one of them fails
+// it opens again
+type Breaker struct {
+	FailureRate 	float64			// between 0 and 1, defaults to 0.5
+	Window 			int				// how many of the latest jobs count, defaults to 20
+	MinRequests 	int				// the breaker doesn't open on fewer jobs than this, defaults to 10
+	OpenFor 		time.Duration	// defaults to 10 seconds
+	Trials 			int				// requests let through while half-open, defaults to 1
+	// OnChange is called from the Balance loop every time the breaker of
+	// a type changes state, it should return quickly
+	OnChange 		func(typ string, from, to BreakerState)
+}
+
+func (c *Breaker) failureRate() float64 {
+	if c.FailureRate <= 0 {
+		return 0.5
+	}
+	return c.FailureRate
+}
+
+func (c *Breaker) window() int {
+	if c.Window <= 0 {
+		return 20
+	}
+	return c.Window
+}
+
+func (c *Breaker) minRequests() int {
+	if c.MinRequests <= 0 {
+		return 10
+	}
+	return c.MinRequests
+}
+
+func (c *Breaker) openFor() time.Duration {
+	if c.OpenFor <= 0 {
+		return 10 * time.Second
+	}
+	return c.OpenFor
+}
+
+func (c *Breaker) trials() int {
+	if c.Trials <= 0 {
+		return 1
+	}
+	return c.Trials
+}
+
+// isolated is what the Balancer keeps track of for a type
+type isolated struct {
+	running 	int
+	waiting 	[]*task		// over the bulkhead
+	state 		BreakerState
+	outcomes 	[]bool		// the latest jobs, true for the failed ones
+	next 		int			// where the next outcome goes in outcomes
+	failures 	int
+	until 		time.Time	// when an open breaker goes half-open
+	trials 		int			// trial requests out while half-open
+	passed 		int			// trial requests which succeeded
+}
+
+func (b *Balancer) isolation(typ string) (Isolation, *isolated) {
+	policy, ok := b.Types[typ]
+	if typ == "" || !ok {
+		return policy, nil
+	}
+	if b.isolated == nil {
+		b.isolated = make(map[string]*isolated)
+	}
+	s := b.isolated[typ]
+	if s == nil {
+		s = &isolated{}
+		b.isolated[typ] = s
+	}
+	return policy, s
+}
+
+// isolate lets t through unless the breaker of its type is open, and holds
+// it back while its type is at the bulkhead limit
+func (b *Balancer) isolate(t *task) {
+	policy, s := b.isolation(t.typ)
+	if s == nil {
+		b.admit(t)
+		return
+	}
+	if policy.MaxRunning > 0 && s.running >= policy.MaxRunning {
+		// the breaker gets its say once the request's turn comes, unless
+		// it's going to stay open for a while yet
+		if s.state == Open && b.clock().Now().Before(s.until) {
+			b.drop(t, "circuit open", ErrCircuitOpen)
+			return
+		}
+		if len(s.waiting) >= policy.maxWaiting() {
+			b.drop(t, "bulkhead full", ErrBulkheadFull)
+			return
+		}
+		s.waiting = append(s.waiting, t)
+		b.bulkheaded++
+		return
+	}
+	if !b.allow(t, policy, s) {
+		b.drop(t, "circuit open", ErrCircuitOpen)
+		return
+	}
+	s.running++
+	b.admit(t)
+}
+
+// retried sends a task back through after its backoff, unless the breaker
+// of its type opened in the meantime
+func (b *Balancer) retried(t *task) {
+	b.backoff--
+	t.enqueued = b.clock().Now()
+	b.phase(t, "workerpool.enqueue")
+	policy, s := b.isolation(t.typ)
+	if s != nil && !b.allow(t, policy, s) {
+		b.drop(t, "circuit open", ErrCircuitOpen)
+		b.vacate(t.typ, policy, s)
+		return
+	}
+	b.admit(t)
+}
+
+// drop rejects t, letting the next request with its key through
+func (b *Balancer) drop(t *task, reason string, err error) {
+	b.reject(t.Request, reason, err)
+	b.untrace(t, err)
+	if t.key != "" {
+		b.unblock(t.key)
+	}
+}
+
+// allow tells whether t can go through the breaker of its type
+func (b *Balancer) allow(t *task, policy Isolation, s *isolated) bool {
+	c := policy.Breaker
+	t.trial = false
+	if c == nil {
+		return true
+	}
+	switch s.state {
+	case Open:
+		if b.clock().Now().Before(s.until) {
+			return false
+		}
+		s.trials, s.passed = 0, 0
+		b.transition(t.typ, c, s, HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if s.trials >= c.trials() {
+			return false
+		}
+		s.trials++
+		t.trial = true
+	}
+	return true
+}
+
+// outcome records how the job of t went, after it ran, and frees its place
+// in the bulkhead once it's done for good
+func (b *Balancer) outcome(t *task, ran, failed, again bool) {
+	policy, s := b.isolation(t.typ)
+	if s == nil {
+		return
+	}
+	if c := policy.Breaker; c != nil {
+		if ran {
+			b.record(t.typ, c, s, failed)
+		} else if t.trial && s.state == HalfOpen {
+			// a trial which didn't run proved nothing, let another one go
+			s.trials--
+		}
+	}
+	if !again {
+		b.vacate(t.typ, policy, s)
+	}
+}
+
+func (b *Balancer) record(typ string, c *Breaker, s *isolated, failed bool) {
+	switch s.state {
+	case HalfOpen:
+		if failed {
+			b.trip(typ, c, s)
+			return
+		}
+		s.passed++
+		if s.passed >= c.trials() {
+			s.outcomes, s.next, s.failures = nil, 0, 0
+			b.transition(typ, c, s, Closed)
+		}
+	case Closed:
+		if len(s.outcomes) < c.window() {
+			s.outcomes = append(s.outcomes, failed)
+		} else {
+			if s.outcomes[s.next] {
+				s.failures--
+			}
+			s.outcomes[s.next] = failed
+			s.next = (s.next + 1) % len(s.outcomes)
+		}
+		if failed {
+			s.failures++
+		}
+		n := len(s.outcomes)
+		if n >= c.minRequests() && float64(s.failures)/float64(n) >= c.failureRate() {
+			b.trip(typ, c, s)
+		}
+	}
+}
+
+// trip opens the breaker and rejects the requests of the type which were
+// waiting at the bulkhead, there's no point in them waiting any longer
+func (b *Balancer) trip(typ string, c *Breaker, s *isolated) {
+	s.until = b.clock().Now().Add(c.openFor())
+	b.transition(typ, c, s, Open)
+	waiting := s.waiting
+	s.waiting = nil
+	b.bulkheaded -= len(waiting)
+	for _, t := range waiting {
+		b.drop(t, "circuit open", ErrCircuitOpen)
+	}
+}
+
+func (b *Balancer) transition(typ string, c *Breaker, s *isolated, to BreakerState) {
+	from := s.state
+	s.state = to
+	if c.OnChange != nil {
+		c.OnChange(typ, from, to)
+	}
+}
+
+// vacate frees a place in the bulkhead and lets the next request waiting
+// on it through
+func (b *Balancer) vacate(typ string, policy Isolation, s *isolated) {
+	s.running--
+	for len(s.waiting) > 0 && (policy.MaxRunning <= 0 || s.running < policy.MaxRunning) {
+		t := s.waiting[0]
+		s.waiting = s.waiting[1:]
+		b.bulkheaded--
+		if !b.allow(t, policy, s) {
+			b.drop(t, "circuit open", ErrCircuitOpen)
+			continue
+		}
+		s.running++
+		b.admit(t)
+	}
+}
@@ -0,0 +1,217 @@
+package Workerpool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runTyped finishes the task on every busy worker, failing them if fail is
+// set, and returns their types
+func runTyped(b *Balancer, fail bool) []string {
+	var (
+		types 	[]string
+		busy 	[]*Worker
+	)
+	for _, w := range *b.Pool {
+		select {
+		case t := <-w.requests:
+			t.ran, t.failed = true, fail
+			types = append(types, t.typ)
+			busy = append(busy, w)
+		default:
+		}
+	}
+	for _, w := range busy {
+		b.complete(w)
+	}
+	return types
+}
+
+func TestBulkhead(t *testing.T) {
+	b := &Balancer{
+		Pool: 	weighted(make([]int, 4), makeWorker),
+		Types: 	map[string]Isolation{"db": {MaxRunning: 2}},
+	}
+	b.lazyInit()
+	for i := 0; i < 6; i++ {
+		b.dispatch(NewRequest(ok, nil, WithType("db")))
+	}
+	b.dispatch(NewRequest(ok, nil, WithType("cpu")))
+	b.dispatch(NewRequest(ok, nil))
+
+	ran := make(map[string]int)
+	for i := 0; i < 3; i++ {
+		n := make(map[string]int)
+		for _, typ := range runTyped(b, false) {
+			n[typ]++
+			ran[typ]++
+		}
+		if n["db"] > 2 {
+			t.Fatalf("got %d db jobs running at once, want at most 2", n["db"])
+		}
+	}
+	if ran["db"] != 6 || ran["cpu"] != 1 || ran[""] != 1 {
+		t.Errorf("got %v, want every job to run", ran)
+	}
+}
+
+func TestBulkheadMaxWaiting(t *testing.T) {
+	b := &Balancer{
+		Pool: 	weighted(make([]int, 2), makeWorker),
+		Types: 	map[string]Isolation{"db": {MaxRunning: 1, MaxWaiting: 2}},
+	}
+	b.lazyInit()
+	result := make(chan Result, 4)
+	for i := 0; i < 4; i++ {
+		b.dispatch(NewRequest(ok, result, WithType("db")))
+	}
+	if r := <-result; r.Err != ErrBulkheadFull {
+		t.Fatalf("got %v, want ErrBulkheadFull", r.Err)
+	}
+	// the type's backlog doesn't keep the others out
+	b.dispatch(NewRequest(ok, nil))
+	if types := runTyped(b, false); len(types) != 2 {
+		t.Errorf("got %q running, want a db job and the other one", types)
+	}
+	// and the two waiting get their turn
+	for i := 0; i < 2; i++ {
+		if types := runTyped(b, false); len(types) != 1 || types[0] != "db" {
+			t.Fatalf("got %q running, want the next db job", types)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	clock := newFakeClock()
+	var changes []string
+	b := &Balancer{
+		Pool: 	weighted(make([]int, 1), makeWorker),
+		Clock: 	clock,
+		Types: 	map[string]Isolation{"api": {Breaker: &Breaker{
+			Window: 		4,
+			MinRequests: 	4,
+			OpenFor: 		time.Second,
+			OnChange: func(typ string, from, to BreakerState) {
+				changes = append(changes, typ+" "+from.String()+" "+to.String())
+			},
+		}}},
+	}
+	b.lazyInit()
+	result := make(chan Result, 1)
+	rejected := func() bool {
+		b.dispatch(NewRequest(ok, result, WithType("api")))
+		select {
+		case r := <-result:
+			return r.Err == ErrCircuitOpen
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}
+
+	// one success and three failures in the window of 4 open it
+	b.dispatch(NewRequest(ok, nil, WithType("api")))
+	runTyped(b, false)
+	for i := 0; i < 3; i++ {
+		b.dispatch(NewRequest(ok, nil, WithType("api")))
+		runTyped(b, true)
+	}
+	if !rejected() {
+		t.Fatal("request went through an open breaker")
+	}
+
+	// a single trial once it's been open for OpenFor, which fails
+	clock.Advance(time.Second)
+	if rejected() {
+		t.Fatal("trial request rejected by a half-open breaker")
+	}
+	if !rejected() {
+		t.Fatal("second request went through a half-open breaker")
+	}
+	runTyped(b, true)
+	if !rejected() {
+		t.Fatal("request went through after the trial failed")
+	}
+
+	// this time the trial succeeds
+	clock.Advance(time.Second)
+	if rejected() {
+		t.Fatal("trial request rejected")
+	}
+	runTyped(b, false)
+	if rejected() {
+		t.Fatal("request rejected by a closed breaker")
+	}
+
+	want := []string{
+		"api closed open",
+		"api open half-open",
+		"api half-open open",
+		"api open half-open",
+		"api half-open closed",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %q, want %q", changes, want)
+	}
+}
+
+func TestBreakerDropsBulkheaded(t *testing.T) {
+	b := &Balancer{
+		Pool: 	weighted(make([]int, 1), makeWorker),
+		Types: 	map[string]Isolation{"api": {
+			MaxRunning: 1,
+			Breaker: 	&Breaker{MinRequests: 1},
+		}},
+	}
+	b.lazyInit()
+	result := make(chan Result, 3)
+	for i := 0; i < 3; i++ {
+		b.dispatch(NewRequest(ok, result, WithType("api")))
+	}
+	if b.waiting() != 2 {
+		t.Fatalf("got %d waiting at the bulkhead, want 2", b.waiting())
+	}
+	runTyped(b, true)
+	for i := 0; i < 2; i++ {
+		if r := <-result; r.Err != ErrCircuitOpen {
+			t.Errorf("got %v, want ErrCircuitOpen", r.Err)
+		}
+	}
+	if b.waiting() != 0 {
+		t.Errorf("got %d still waiting", b.waiting())
+	}
+}
+
+func TestBreakerTrialCanceled(t *testing.T) {
+	clock := newFakeClock()
+	b := &Balancer{
+		Pool: 	weighted(make([]int, 1), makeWorker),
+		Clock: 	clock,
+		Types: 	map[string]Isolation{"api": {Breaker: &Breaker{Window: 1, MinRequests: 1, OpenFor: time.Second}}},
+	}
+	b.lazyInit()
+	b.dispatch(NewRequest(ok, nil, WithType("api")))
+	runTyped(b, true)
+
+	// the trial is canceled while it's queued
+	clock.Advance(time.Second)
+	b.paused = true
+	result := make(chan Result, 1)
+	b.dispatch(NewRequest(ok, result, WithType("api")))
+	if !b.cancel((*b.Pool)[0].queue[0].seq) {
+		t.Fatal("couldn't cancel the trial")
+	}
+	if r := <-result; r.Err != ErrCanceled {
+		t.Fatalf("got %v, want ErrCanceled", r.Err)
+	}
+	b.paused = false
+
+	// so the next request gets to be the trial
+	b.dispatch(NewRequest(ok, result, WithType("api")))
+	if types := runTyped(b, false); len(types) != 1 {
+		t.Fatal("the breaker let no trial through")
+	}
+	if s := b.isolated["api"]; s.state != Closed {
+		t.Errorf("got %v, want the breaker closed", s.state)
+	}
+}
@@ -0,0 +1,114 @@
+// Package client talks to a Workerpool.Server over HTTP
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	Workerpool ".."
+)
+
+// Client submits and keeps track of tasks on a Workerpool.Server
+type Client struct {
+	URL 	string			// where the server is, eg http://localhost:7070
+	HTTP 	*http.Client	// defaults to http.DefaultClient
+}
+
+func New(url string) *Client {
+	return &Client{URL: url}
+}
+
+// Error is returned when the server answers with an error
+type Error struct {
+	Code 	int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("workerpool server: %d %s", e.Code, e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.URL+path, &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	h := c.HTTP
+	if h == nil {
+		h = http.DefaultClient
+	}
+	resp, err := h.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return &Error{Code: resp.StatusCode, Message: e.Error}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Submit submits a task, the returned status holds its id
+func (c *Client) Submit(ctx context.Context, spec Workerpool.TaskSpec) (Workerpool.TaskStatus, error) {
+	var status Workerpool.TaskStatus
+	err := c.do(ctx, "POST", "/tasks", spec, &status)
+	return status, err
+}
+
+// Status returns where task id is at
+func (c *Client) Status(ctx context.Context, id uint64) (Workerpool.TaskStatus, error) {
+	var status Workerpool.TaskStatus
+	err := c.do(ctx, "GET", "/tasks/"+strconv.FormatUint(id, 10), nil, &status)
+	return status, err
+}
+
+// Wait polls task id every interval until it's done or canceled
+func (c *Client) Wait(ctx context.Context, id uint64, interval time.Duration) (Workerpool.TaskStatus, error) {
+	for {
+		status, err := c.Status(ctx, id)
+		if err != nil || status.State == Workerpool.StateDone || status.State == Workerpool.StateCanceled {
+			return status, err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
+
+// Cancel cancels task id if it's not finished, or has the server forget
+// about it if it is
+func (c *Client) Cancel(ctx context.Context, id uint64) (Workerpool.TaskStatus, error) {
+	var status Workerpool.TaskStatus
+	err := c.do(ctx, "DELETE", "/tasks/"+strconv.FormatUint(id, 10), nil, &status)
+	return status, err
+}
+
+// Types returns the task types the server can run
+func (c *Client) Types(ctx context.Context) ([]string, error) {
+	var types []string
+	err := c.do(ctx, "GET", "/types", nil, &types)
+	return types, err
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	Workerpool ".."
+)
+
+func start(t *testing.T) *Client {
+	return startRetaining(t, 0)
+}
+
+// startRetaining starts a server which forgets finished tasks after retain
+func startRetaining(t *testing.T, retain time.Duration) *Client {
+	done := make(chan *Workerpool.Worker)
+	b := &Workerpool.Balancer{Pool: Workerpool.New(2, done), Done: done}
+	requests := make(chan Workerpool.Request)
+	go b.Balance(requests)
+	t.Cleanup(func() { close(requests) })
+
+	registry := Workerpool.NewRegistry()
+	registry.Register("double", func(_ context.Context, payload []byte) (int, error) {
+		n, err := strconv.Atoi(string(payload))
+		return 2 * n, err
+	})
+	registry.Register("block", func(ctx context.Context, _ []byte) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	server := Workerpool.NewServer(registry, requests)
+	server.Retain = retain
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	return New(srv.URL)
+}
+
+func TestClient(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	types, err := c.Types(ctx)
+	if err != nil || len(types) != 2 || types[0] != "block" {
+		t.Fatalf("got types %v with %v", types, err)
+	}
+
+	status, err := c.Submit(ctx, Workerpool.TaskSpec{Type: "double", Payload: "21"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err = c.Wait(ctx, status.ID, time.Millisecond)
+	if err != nil || status.State != Workerpool.StateDone || status.Value != 42 {
+		t.Fatalf("got %+v with %v, want 42", status, err)
+	}
+
+	status, _ = c.Submit(ctx, Workerpool.TaskSpec{Type: "double", Payload: "x"})
+	status, _ = c.Wait(ctx, status.ID, time.Millisecond)
+	if status.Error == "" {
+		t.Errorf("got %+v, want the job to fail", status)
+	}
+
+	// forgetting a finished task
+	if _, err := c.Cancel(ctx, status.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Status(ctx, status.ID); err == nil || err.(*Error).Code != 404 {
+		t.Errorf("got %v, want a 404 for a forgotten task", err)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	status, err := c.Submit(ctx, Workerpool.TaskSpec{Type: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err = c.Cancel(ctx, status.ID); err != nil || status.State != Workerpool.StateCanceled {
+		t.Fatalf("got %+v with %v, want it canceled", status, err)
+	}
+	status, err = c.Wait(ctx, status.ID, time.Millisecond)
+	if err != nil || status.State != Workerpool.StateCanceled {
+		t.Errorf("got %+v with %v, want it canceled", status, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := start(t)
+	ctx := context.Background()
+
+	if _, err := c.Submit(ctx, Workerpool.TaskSpec{Type: "nope"}); err == nil || err.(*Error).Code != 400 {
+		t.Errorf("got %v, want a 400 for an unknown type", err)
+	}
+	if _, err := c.Status(ctx, 12345); err == nil || err.(*Error).Code != 404 {
+		t.Errorf("got %v, want a 404", err)
+	}
+}
+
+func TestClientRetain(t *testing.T) {
+	c := startRetaining(t, 20*time.Millisecond)
+	ctx := context.Background()
+
+	status, err := c.Submit(ctx, Workerpool.TaskSpec{Type: "double", Payload: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err = c.Wait(ctx, status.ID, time.Millisecond); err != nil || status.State != Workerpool.StateDone {
+		t.Fatalf("got %+v with %v", status, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := c.Status(ctx, status.ID)
+		if e, ok := err.(*Error); ok && e.Code == 404 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("finished task still there after %v: %v", time.Second, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,21 @@
+package Workerpool
+
+import "time"
+
+// Clock tells the time to the parts of the pool which wait on it, so tests
+// can use a fake one instead of sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the default Clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
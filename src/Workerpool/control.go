@@ -0,0 +1,267 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrCanceled is the error of a request taken out of the queue with Cancel
+var ErrCanceled = errors.New("Workerpool: request canceled")
+
+// The methods below are safe to call from any goroutine while Balance is
+// running, they run in the Balance loop and block until it got to them
+
+// control returns the channel the Balance loop takes calls from
+func (b *Balancer) control() chan func() {
+	b.controlOnce.Do(func() {
+		b.calls = make(chan func())
+	})
+	return b.calls
+}
+
+// do runs f in the Balance loop and waits for it
+func (b *Balancer) do(f func()) {
+	done := make(chan struct{})
+	b.control() <- func() {
+		f()
+		close(done)
+	}
+	<-done
+}
+
+// Pause stops handing requests to the workers, the ones already running
+// finish but everything else stays queued until Resume. The Balancer keeps
+// taking requests until the queues are full
+func (b *Balancer) Pause() {
+	b.do(func() {
+		b.paused = true
+	})
+}
+
+// Resume undoes Pause
+func (b *Balancer) Resume() {
+	b.do(func() {
+		b.paused = false
+		// feeding reorders the heap, so go by a copy
+		for _, w := range append([]*Worker(nil), *b.Pool...) {
+			b.feed(w)
+			b.report(w)
+		}
+		b.deal()
+	})
+}
+
+// TaskInfo describes a request the Balancer is holding on to
+type TaskInfo struct {
+	ID 			uint64
+	Type 		string
+	Tenant 		string
+	Priority 	int
+	Age 		time.Duration	// since the request came in, or came back for a retry
+	Attempt 	int				// the attempt running, or going to run next
+}
+
+// WorkerInfo describes a worker and its queue
+type WorkerInfo struct {
+	ID 			int
+	Capacity 	int
+	Running 	*TaskInfo		// nil when the worker is idle
+	Queued 		[]TaskInfo		// in the order they are going to run
+}
+
+// Snapshot is what Inspect returns
+type Snapshot struct {
+	Paused 		bool
+	Workers 	[]WorkerInfo	// ordered by ID
+	Waiting 	[]TaskInfo		// held back before a worker queue, by the limit, a key, a bulkhead, Fair or in the shared queue
+}
+
+// Inspect lists what's running and queued on every worker
+func (b *Balancer) Inspect() Snapshot {
+	var s Snapshot
+	b.do(func() {
+		now := b.clock().Now()
+		s.Paused = b.paused
+		for _, w := range *b.Pool {
+			wi := WorkerInfo{ID: w.id, Capacity: w.capacity}
+			if w.running != nil {
+				info := w.running.info(now)
+				info.Attempt = w.running.tries
+				wi.Running = &info
+			}
+			queued := append(queue(nil), w.queue...)
+			sort.Slice(queued, queued.Less)
+			for _, t := range queued {
+				wi.Queued = append(wi.Queued, t.info(now))
+			}
+			s.Workers = append(s.Workers, wi)
+		}
+		sort.Slice(s.Workers, func(i, j int) bool { return s.Workers[i].ID < s.Workers[j].ID })
+		b.waitingTasks(func(t *task) {
+			s.Waiting = append(s.Waiting, t.info(now))
+		})
+		sort.Slice(s.Waiting, func(i, j int) bool { return s.Waiting[i].ID < s.Waiting[j].ID })
+	})
+	return s
+}
+
+// info describes a task which isn't running
+func (t *task) info(now time.Time) TaskInfo {
+	return TaskInfo{
+		ID: 		t.seq,
+		Type: 		t.typ,
+		Tenant: 	t.tenant,
+		Priority: 	t.priority,
+		Age: 		now.Sub(t.enqueued),
+		Attempt: 	t.tries + 1,
+	}
+}
+
+// waitingTasks calls f for every task held back before the worker queues
+func (b *Balancer) waitingTasks(f func(*task)) {
+	if b.limiter != nil {
+		for _, held := range b.limiter.held {
+			for _, t := range held {
+				f(t)
+			}
+		}
+	}
+	for _, waiting := range b.keys {
+		for _, t := range waiting {
+			f(t)
+		}
+	}
+	for _, s := range b.isolated {
+		for _, t := range s.waiting {
+			f(t)
+		}
+	}
+	if b.fair != nil {
+		for _, q := range b.fair.tenants {
+			for _, t := range q.tasks {
+				f(t)
+			}
+		}
+	}
+	for _, t := range b.shared {
+		f(t)
+	}
+}
+
+// Cancel takes the request with the given ID out of the queue and fails it
+// with ErrCanceled. It returns false if there's no such request waiting, it
+// may be running already or be done
+func (b *Balancer) Cancel(id uint64) bool {
+	var found bool
+	b.do(func() {
+		found = b.cancel(id)
+	})
+	return found
+}
+
+func (b *Balancer) cancel(id uint64) bool {
+	var t *task
+	match := func(tasks []*task) []*task {
+		for i, c := range tasks {
+			if c.seq == id {
+				t = c
+				return append(tasks[:i], tasks[i+1:]...)
+			}
+		}
+		return tasks
+	}
+
+	// waiting on another request with the same key, it holds nothing yet
+	for key, waiting := range b.keys {
+		if b.keys[key] = match(waiting); t != nil {
+			b.keyed--
+			b.reject(t.Request, "canceled", ErrCanceled)
+			b.untrace(t, ErrCanceled)
+			return true
+		}
+	}
+	// waiting at a bulkhead, it only holds its key
+	for _, s := range b.isolated {
+		if s.waiting = match(s.waiting); t != nil {
+			b.bulkheaded--
+			b.drop(t, "canceled", ErrCanceled)
+			return true
+		}
+	}
+	// past the bulkhead
+	if b.limiter != nil {
+		l := b.limiter
+		for tenant, held := range l.held {
+			if l.held[tenant] = match(held); t != nil {
+				l.n--
+				if len(l.held[tenant]) == 0 {
+					delete(l.held, tenant)
+					for i, o := range l.order {
+						if o == tenant {
+							l.order = append(l.order[:i], l.order[i+1:]...)
+							break
+						}
+					}
+				}
+				b.abandon(t, "canceled", ErrCanceled)
+				return true
+			}
+		}
+	}
+	if b.fair != nil {
+		for _, q := range b.fair.tenants {
+			if q.tasks = match(q.tasks); t != nil {
+				b.fair.n--
+				b.abandon(t, "canceled", ErrCanceled)
+				return true
+			}
+		}
+	}
+	for i, c := range b.shared {
+		if c.seq == id {
+			heap.Remove(&b.shared, i)
+			b.abandon(c, "canceled", ErrCanceled)
+			return true
+		}
+	}
+	for _, w := range *b.Pool {
+		for i, c := range w.queue {
+			if c.seq != id {
+				continue
+			}
+			heap.Remove(&w.queue, i)
+			b.queued--
+			w.pending--
+			if w.pending == 0 {
+				w.idle = b.clock().Now()
+			}
+			heap.Fix(b.Pool, w.index)
+			b.report(w)
+			if b.fair != nil {
+				if q := b.fair.tenants[c.tenant]; q != nil {
+					q.running--
+					q.vtime -= c.cost
+				}
+			}
+			b.abandon(c, "canceled", ErrCanceled)
+			b.deal()
+			return true
+		}
+	}
+	return false
+}
+
+// abandon fails a task which got past the bulkhead, giving its place to the
+// next request of its type and of its key
+func (b *Balancer) abandon(t *task, reason string, err error) {
+	b.drop(t, reason, err)
+	if policy, s := b.isolation(t.typ); s != nil {
+		if t.trial && s.state == HalfOpen {
+			// it proved nothing, let another one go
+			s.trials--
+		}
+		b.vacate(t.typ, policy, s)
+	}
+}
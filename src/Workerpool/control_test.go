@@ -0,0 +1,133 @@
+package Workerpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	b := &Balancer{}
+	requests := startBalancer(t, 2, b)
+	b.Pause()
+	result := make(chan Result, 3)
+	for i := 0; i < 3; i++ {
+		requests <- NewRequest(ok, result)
+	}
+	select {
+	case <-result:
+		t.Fatal("a job ran while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s := b.Inspect()
+	queued := 0
+	for _, w := range s.Workers {
+		if w.Running != nil {
+			t.Errorf("worker %d is running a task while paused", w.ID)
+		}
+		queued += len(w.Queued)
+	}
+	if !s.Paused || queued != 3 {
+		t.Errorf("got paused %v with %d queued, want 3", s.Paused, queued)
+	}
+
+	b.Resume()
+	for i := 0; i < 3; i++ {
+		if r := <-result; r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	b := &Balancer{}
+	requests := startBalancer(t, 1, b)
+	gate := make(chan struct{})
+	result := make(chan Result, 2)
+	requests <- NewRequest(func(context.Context) (int, error) {
+		<-gate
+		return 0, nil
+	}, result, WithType("slow"), WithTenant("acme"))
+	requests <- NewRequest(ok, result, WithType("quick"), WithPriority(2))
+
+	s := b.Inspect()
+	if len(s.Workers) != 1 {
+		t.Fatalf("got %d workers, want 1", len(s.Workers))
+	}
+	w := s.Workers[0]
+	if w.Running == nil || w.Running.Type != "slow" || w.Running.Tenant != "acme" || w.Running.Attempt != 1 {
+		t.Errorf("got running %+v, want the slow job on its first attempt", w.Running)
+	}
+	if len(w.Queued) != 1 || w.Queued[0].Type != "quick" || w.Queued[0].Priority != 2 || w.Queued[0].Attempt != 1 {
+		t.Errorf("got queued %+v, want the quick job", w.Queued)
+	}
+	if w.Running != nil && len(w.Queued) == 1 && w.Running.ID >= w.Queued[0].ID {
+		t.Errorf("running task has id %d, after the queued one %d", w.Running.ID, w.Queued[0].ID)
+	}
+	close(gate)
+	<-result
+	<-result
+}
+
+func TestCancel(t *testing.T) {
+	b := &Balancer{}
+	requests := startBalancer(t, 1, b)
+	b.Pause()
+	first, second := make(chan Result, 1), make(chan Result, 1)
+	requests <- NewRequest(ok, first)
+	requests <- NewRequest(ok, second)
+
+	queued := b.Inspect().Workers[0].Queued
+	if len(queued) != 2 {
+		t.Fatalf("got %d queued, want 2", len(queued))
+	}
+	if !b.Cancel(queued[0].ID) {
+		t.Fatal("couldn't cancel a queued task")
+	}
+	if r := <-first; r.Err != ErrCanceled {
+		t.Errorf("got %v, want ErrCanceled", r.Err)
+	}
+	if b.Cancel(queued[0].ID) {
+		t.Error("canceled the same task twice")
+	}
+
+	b.Resume()
+	if r := <-second; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if b.Cancel(queued[1].ID) {
+		t.Error("canceled a task which is done")
+	}
+}
+
+func TestCancelKeyed(t *testing.T) {
+	b := &Balancer{}
+	requests := startBalancer(t, 1, b)
+	b.Pause()
+	first, second := make(chan Result, 1), make(chan Result, 1)
+	requests <- NewRequest(ok, first, WithKey("k"))
+	requests <- NewRequest(ok, second, WithKey("k"))
+
+	s := b.Inspect()
+	if len(s.Workers[0].Queued) != 1 || len(s.Waiting) != 1 {
+		t.Fatalf("got %d queued and %d waiting, want one of each", len(s.Workers[0].Queued), len(s.Waiting))
+	}
+	// the next request with the key takes the place of the canceled one
+	b.Cancel(s.Workers[0].Queued[0].ID)
+	<-first
+	s = b.Inspect()
+	if len(s.Workers[0].Queued) != 1 || len(s.Waiting) != 0 {
+		t.Errorf("got %d queued and %d waiting after the cancel", len(s.Workers[0].Queued), len(s.Waiting))
+	}
+
+	b.Resume()
+	select {
+	case r := <-second:
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request waiting on the key never ran")
+	}
+}
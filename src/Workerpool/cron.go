@@ -0,0 +1,167 @@
+package Workerpool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a scheduled job runs next
+type Schedule interface {
+	// Next returns the first time the job runs after t, or the zero time
+	// if it never runs again
+	Next(t time.Time) time.Time
+}
+
+type at time.Time
+
+// At runs a job once, at t
+func At(t time.Time) Schedule {
+	return at(t)
+}
+
+func (a at) Next(t time.Time) time.Time {
+	if time.Time(a).After(t) {
+		return time.Time(a)
+	}
+	return time.Time{}
+}
+
+type every time.Duration
+
+// Every runs a job at fixed intervals
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+func (e every) Next(t time.Time) time.Time {
+	if e <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(e))
+}
+
+// cron is a parsed cron expression, a bit set of the allowed values per field
+type cron struct {
+	minute, hour, dom, month, dow 	uint64
+	anyDom, anyDow 					bool
+}
+
+var cronFields = []struct {
+	name 		string
+	min, max 	int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a standard five field cron expression:
+// 	minute hour day-of-month month day-of-week
+// Fields can be *, a value, a range like 1-5, a step like */15 or 0-30/10, or
+// a list of those like 1,15,30. Sunday is both 0 and 7 in the day of the week.
+// As with cron, when both days are restricted a job runs when either matches
+func ParseCron(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Workerpool: cron expression %q should have 5 fields", expr)
+	}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("Workerpool: cron expression %q, %s: %v", expr, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	c := &cron{
+		minute: 	sets[0],
+		hour: 		sets[1],
+		dom: 		sets[2],
+		month: 		sets[3],
+		dow: 		sets[4],
+		anyDom: 	fields[2] == "*",
+		anyDow: 	fields[4] == "*",
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := min, max, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("bad step %q", part[i+1:])
+			}
+			step = s
+			part = part[:i]
+		}
+		if part != "*" {
+			var err error
+			if i := strings.Index(part, "-"); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				if step == 1 {
+					hi = lo
+				}
+			}
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%d-%d out of range %d-%d", lo, hi, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// a valid expression matches at least once every few years, leap days included
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
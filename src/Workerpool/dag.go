@@ -0,0 +1,195 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrCycle is returned by DAG.Run when the dependencies go round in a circle
+	ErrCycle = errors.New("Workerpool: dependency cycle")
+	// ErrSkipped is the error of a node which didn't run because a node it
+	// depends on failed
+	ErrSkipped = errors.New("Workerpool: dependency failed")
+)
+
+// NodeFunc is the job of a node in a DAG, deps holds the values of the nodes
+// it depends on by name
+type NodeFunc func(ctx context.Context, deps map[string]int) (int, error)
+
+// FailurePolicy says what a DAG does when one of its nodes fails
+type FailurePolicy int
+
+const (
+	// SkipDescendants doesn't run anything depending on the failed node, but
+	// carries on with the rest of the DAG
+	SkipDescendants FailurePolicy = iota
+	// CancelAll cancels everything still running or waiting to run
+	CancelAll
+)
+
+type node struct {
+	name 		string
+	fn 			NodeFunc
+	deps 		[]string
+	dependents 	[]*node
+	waiting 	int		// dependencies which haven't finished yet
+}
+
+// DAG is a set of jobs which depend on each other. Every node runs as soon as all
+// its dependencies finished, through the Balancer like any other Request. A DAG
+// can be run many times but not concurrently
+type DAG struct {
+	nodes map[string]*node
+}
+
+func NewDAG() *DAG {
+	return &DAG{nodes: make(map[string]*node)}
+}
+
+// Add adds a node running fn once all the nodes in deps are done, the
+// dependencies don't need to be added before
+func (d *DAG) Add(name string, fn NodeFunc, deps ...string) error {
+	if _, ok := d.nodes[name]; ok {
+		return fmt.Errorf("Workerpool: node %q added twice", name)
+	}
+	d.nodes[name] = &node{name: name, fn: fn, deps: deps}
+	return nil
+}
+
+// link works out the dependents of every node, and makes sure every dependency
+// exists and there are no cycles before anything runs
+func (d *DAG) link() error {
+	for _, n := range d.nodes {
+		n.dependents = nil
+	}
+	for _, n := range d.nodes {
+		n.waiting = len(n.deps)
+		for _, dep := range n.deps {
+			p, ok := d.nodes[dep]
+			if !ok {
+				return fmt.Errorf("Workerpool: node %q depends on unknown node %q", n.name, dep)
+			}
+			p.dependents = append(p.dependents, n)
+		}
+	}
+
+	// Kahn's algorithm, if some nodes can never be reached there's a cycle
+	waiting := make(map[*node]int, len(d.nodes))
+	var ready []*node
+	for _, n := range d.nodes {
+		waiting[n] = n.waiting
+		if n.waiting == 0 {
+			ready = append(ready, n)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		n := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, c := range n.dependents {
+			if waiting[c]--; waiting[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	if visited != len(d.nodes) {
+		return ErrCycle
+	}
+	return nil
+}
+
+type nodeResult struct {
+	node 	*node
+	result 	Result
+}
+
+// Run sends the nodes to requests as they become ready and waits for all of them,
+// returning the result of every node and the first error if any. opts apply to
+// the Request of every node
+func (d *DAG) Run(ctx context.Context, requests chan<- Request, policy FailurePolicy, opts ...RequestOption) (map[string]Result, error) {
+	if err := d.link(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(map[string]Result, len(d.nodes))
+	finished := make(chan nodeResult, len(d.nodes))
+	var ready []*node
+	for _, n := range d.nodes {
+		if n.waiting == 0 {
+			ready = append(ready, n)
+		}
+	}
+	// run in the same order every time
+	sort.Slice(ready, func(i, j int) bool { return ready[i].name < ready[j].name })
+
+	var firstErr error
+	running := 0
+	// finish records the result of n and returns the dependents which became ready
+	var finish func(n *node, r Result) []*node
+	finish = func(n *node, r Result) (next []*node) {
+		results[n.name] = r
+		if r.Err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Workerpool: node %q: %w", n.name, r.Err)
+			if policy == CancelAll {
+				cancel()
+			}
+		}
+		for _, c := range n.dependents {
+			if _, done := results[c.name]; done {
+				continue
+			}
+			if r.Err != nil {
+				// skipping c skips its own dependents as well
+				finish(c, Result{Err: ErrSkipped})
+				continue
+			}
+			if c.waiting--; c.waiting == 0 {
+				next = append(next, c)
+			}
+		}
+		return next
+	}
+
+	for len(ready) > 0 || running > 0 {
+		for len(ready) > 0 {
+			n := ready[0]
+			ready = ready[1:]
+			if ctx.Err() != nil {
+				ready = append(ready, finish(n, Result{Err: ctx.Err()})...)
+				continue
+			}
+
+			deps := make(map[string]int, len(n.deps))
+			for _, dep := range n.deps {
+				deps[dep] = results[dep].Value
+			}
+			job := func(fn NodeFunc) Job {
+				return func(ctx context.Context) (int, error) {
+					return fn(ctx, deps)
+				}
+			}(n.fn)
+			result := make(chan Result, 1)
+			select {
+			case requests <- NewRequest(job, result, append(append([]RequestOption(nil), opts...), WithContext(ctx))...):
+				running++
+				go func(n *node) {
+					finished <- nodeResult{n, <-result}
+				}(n)
+			case <-ctx.Done():
+				ready = append(ready, finish(n, Result{Err: ctx.Err()})...)
+			}
+		}
+		if running > 0 {
+			r := <-finished
+			running--
+			ready = append(ready, finish(r.node, r.result)...)
+		}
+	}
+	return results, firstErr
+}
@@ -0,0 +1,125 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func value(v int) NodeFunc {
+	return func(context.Context, map[string]int) (int, error) {
+		return v, nil
+	}
+}
+
+func sum(_ context.Context, deps map[string]int) (int, error) {
+	total := 0
+	for _, v := range deps {
+		total += v
+	}
+	return total, nil
+}
+
+func TestDAG(t *testing.T) {
+	requests := startBalancer(t, 3, &Balancer{})
+
+	// a diamond, d adds up b and c which both add up a
+	d := NewDAG()
+	d.Add("d", sum, "b", "c")
+	d.Add("a", value(1))
+	d.Add("b", func(ctx context.Context, deps map[string]int) (int, error) {
+		return deps["a"] + 10, nil
+	}, "a")
+	d.Add("c", func(ctx context.Context, deps map[string]int) (int, error) {
+		return deps["a"] + 100, nil
+	}, "a")
+
+	results, err := d.Run(context.Background(), requests, SkipDescendants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results["d"].Value != 112 {
+		t.Errorf("got %d, want 112", results["d"].Value)
+	}
+
+	if err := d.Add("a", value(2)); err == nil {
+		t.Errorf("added the same node twice")
+	}
+}
+
+func TestDAGInvalid(t *testing.T) {
+	requests := startBalancer(t, 1, &Balancer{})
+
+	d := NewDAG()
+	d.Add("a", sum, "c")
+	d.Add("b", sum, "a")
+	d.Add("c", sum, "b")
+	d.Add("x", value(1))
+	if _, err := d.Run(context.Background(), requests, SkipDescendants); err != ErrCycle {
+		t.Errorf("got %v, want ErrCycle", err)
+	}
+
+	d = NewDAG()
+	d.Add("a", sum, "missing")
+	if _, err := d.Run(context.Background(), requests, SkipDescendants); err == nil {
+		t.Errorf("ran with a missing dependency")
+	}
+}
+
+var errNode = errors.New("node failed")
+
+func fail(context.Context, map[string]int) (int, error) {
+	return 0, errNode
+}
+
+func TestDAGSkipDescendants(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	d := NewDAG()
+	d.Add("a", fail)
+	d.Add("b", sum, "a")
+	d.Add("c", sum, "b", "x")
+	d.Add("x", value(1))
+	d.Add("y", sum, "x")
+
+	results, err := d.Run(context.Background(), requests, SkipDescendants)
+	if !errors.Is(err, errNode) {
+		t.Errorf("got %v, want the error of a", err)
+	}
+	for _, name := range []string{"b", "c"} {
+		if results[name].Err != ErrSkipped {
+			t.Errorf("%s: got %v, want ErrSkipped", name, results[name].Err)
+		}
+	}
+	if results["y"].Err != nil || results["y"].Value != 1 {
+		t.Errorf("y: got %+v, want it to run", results["y"])
+	}
+}
+
+func TestDAGCancelAll(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	started := make(chan struct{})
+	d := NewDAG()
+	d.Add("slow", func(ctx context.Context, _ map[string]int) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	d.Add("a", func(context.Context, map[string]int) (int, error) {
+		<-started
+		return 0, errNode
+	})
+	d.Add("b", sum, "slow")
+
+	results, err := d.Run(context.Background(), requests, CancelAll)
+	if !errors.Is(err, errNode) {
+		t.Errorf("got %v, want the error of a", err)
+	}
+	if results["slow"].Err != context.Canceled {
+		t.Errorf("slow: got %v, want it canceled", results["slow"].Err)
+	}
+	if results["b"].Err != ErrSkipped {
+		t.Errorf("b: got %v, want ErrSkipped", results["b"].Err)
+	}
+}
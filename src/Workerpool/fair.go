@@ -0,0 +1,170 @@
+package Workerpool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTenantFull is the error of a request whose tenant already has
+// Fair.MaxQueued requests waiting
+var ErrTenantFull = errors.New("Workerpool: too many requests queued for the tenant")
+
+// Fair shares the workers between tenants (see WithTenant) by the worker time
+// they used, so a tenant flooding the Balancer only slows itself down. Requests
+// wait in a queue per tenant and only go to a worker once it's idle, the tenant
+// which is furthest behind its share goes first. A tenant with weight 2 gets
+// twice the worker time of a tenant with weight 1 when both have work waiting
+type Fair struct {
+	Weight 		int				// weight of the tenants not in Weights, defaults to 1
+	Weights 	map[string]int	// weights for specific tenants
+	MaxRunning 	int				// how many requests of a tenant can run at once, no cap when 0
+	Running 	map[string]int	// caps for specific tenants
+	MaxQueued 	int				// requests a tenant can have waiting, more are rejected, defaults to 30
+}
+
+func (f *Fair) weight(tenant string) int {
+	w, ok := f.Weights[tenant]
+	if !ok {
+		w = f.Weight
+	}
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+func (f *Fair) running(tenant string) int {
+	if n, ok := f.Running[tenant]; ok {
+		return n
+	}
+	return f.MaxRunning
+}
+
+func (f *Fair) maxQueued() int {
+	if f.MaxQueued <= 0 {
+		return int(defaultSize)
+	}
+	return f.MaxQueued
+}
+
+// tenantQueue is where the requests of a tenant wait for an idle worker.
+// vtime is the worker time the tenant used divided by its weight, the tenant
+// with the lowest one is the next to go
+type tenantQueue struct {
+	tasks 		[]*task
+	vtime 		time.Duration
+	running 	int
+	avg 		time.Duration	// moving average of the run time of the tenant's jobs
+}
+
+// cost is what a task of the tenant is expected to take before it ran
+func (q *tenantQueue) cost() time.Duration {
+	if q.avg == 0 {
+		return time.Millisecond
+	}
+	return q.avg
+}
+
+type fair struct {
+	tenants 	map[string]*tenantQueue
+	vtime 		time.Duration	// vtime of the last tenant to go
+	n 			int				// queued tasks
+}
+
+// share queues t with the other requests of its tenant, or hands it to the
+// Strategy straight away when there's no Fair
+func (b *Balancer) share(t *task) {
+	if b.Fair == nil {
+		b.enqueue(t)
+		return
+	}
+	if b.fair == nil {
+		b.fair = &fair{tenants: make(map[string]*tenantQueue)}
+	}
+
+	q := b.fair.tenants[t.tenant]
+	if q == nil {
+		q = &tenantQueue{}
+		b.fair.tenants[t.tenant] = q
+	}
+	if len(q.tasks) >= b.Fair.maxQueued() {
+		b.abandon(t, "tenant full", ErrTenantFull)
+		return
+	}
+	// a tenant coming back after a while doesn't get credit for the time
+	// it had nothing to run
+	if len(q.tasks) == 0 && q.running == 0 && q.vtime < b.fair.vtime {
+		q.vtime = b.fair.vtime
+	}
+	q.tasks = append(q.tasks, t)
+	b.fair.n++
+	b.deal()
+}
+
+// deal hands the tasks in the shared queue to the workers with room for them,
+// then those in the tenant queues to the idle workers, the tenant furthest
+// behind its share first
+func (b *Balancer) deal() {
+	b.pull()
+	if b.fair == nil {
+		return
+	}
+	for b.fair.n > 0 && b.Pool.Len() > 0 && (*b.Pool)[0].pending == 0 {
+		var (
+			next 	*tenantQueue
+			name 	string
+		)
+		for tenant, q := range b.fair.tenants {
+			if len(q.tasks) == 0 {
+				continue
+			}
+			if max := b.Fair.running(tenant); max > 0 && q.running >= max {
+				continue
+			}
+			if next == nil || q.vtime < next.vtime || (q.vtime == next.vtime && tenant < name) {
+				next, name = q, tenant
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		t := next.tasks[0]
+		next.tasks = next.tasks[1:]
+		b.fair.n--
+		next.running++
+		// charge what the task is expected to take now, so the tenant doesn't
+		// grab every idle worker, and settle up with the real time once it ran
+		t.cost = next.cost() / time.Duration(b.Fair.weight(t.tenant))
+		next.vtime += t.cost
+		b.fair.vtime = next.vtime
+		b.assign((*b.Pool)[0], t)
+	}
+}
+
+// settle charges the tenant of t for the worker time it actually used
+func (b *Balancer) settle(t *task, run time.Duration) {
+	b.metrics().TenantDone(t.tenant, run)
+	if b.fair == nil {
+		return
+	}
+	q := b.fair.tenants[t.tenant]
+	q.running--
+	q.vtime += run/time.Duration(b.Fair.weight(t.tenant)) - t.cost
+	if q.avg == 0 {
+		q.avg = run
+	} else {
+		q.avg += (run - q.avg) / 8
+	}
+	if len(q.tasks) == 0 && q.running == 0 {
+		delete(b.fair.tenants, t.tenant)
+	}
+}
+
+// queued returns the number of tasks waiting in the tenant queues
+func (f *fair) queued() int {
+	if f == nil {
+		return 0
+	}
+	return f.n
+}
@@ -0,0 +1,157 @@
+package Workerpool
+
+import (
+	"testing"
+	"time"
+)
+
+// fairBalancer returns a Balancer whose workers don't run anything, the test
+// finishes their tasks with finish
+func fairBalancer(workers int, f *Fair) *Balancer {
+	b := &Balancer{Pool: weighted(make([]int, workers), makeWorker), Fair: f}
+	b.lazyInit()
+	return b
+}
+
+func submit(b *Balancer, tenant string, n int, result chan Result) {
+	for i := 0; i < n; i++ {
+		b.dispatch(NewRequest(ok, result, WithTenant(tenant)))
+	}
+}
+
+// runFair completes the task running on every busy worker, as if it took as
+// long as took says, and returns the tenants of the tasks
+func runFair(b *Balancer, took func(tenant string) time.Duration) []string {
+	var (
+		tenants []string
+		busy 	[]*Worker
+	)
+	for _, w := range *b.Pool {
+		select {
+		case t := <-w.requests:
+			t.elapsed = took(t.tenant)
+			tenants = append(tenants, t.tenant)
+			busy = append(busy, w)
+		default:
+		}
+	}
+	for _, w := range busy {
+		b.complete(w)
+	}
+	return tenants
+}
+
+func millisecond(string) time.Duration {
+	return time.Millisecond
+}
+
+func TestFairNoisyTenant(t *testing.T) {
+	b := fairBalancer(1, &Fair{})
+	submit(b, "noisy", 30, nil)
+	submit(b, "quiet", 3, nil)
+
+	var quiet int
+	for i := 0; i < 8; i++ {
+		for _, tenant := range runFair(b, millisecond) {
+			if tenant == "quiet" {
+				quiet++
+			}
+		}
+	}
+	if quiet != 3 {
+		t.Errorf("quiet tenant ran %d of its 3 requests behind 30 noisy ones", quiet)
+	}
+}
+
+func TestFairWeights(t *testing.T) {
+	b := fairBalancer(2, &Fair{Weights: map[string]int{"gold": 3}})
+	submit(b, "gold", 30, nil)
+	submit(b, "basic", 30, nil)
+
+	ran := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		for _, tenant := range runFair(b, millisecond) {
+			ran[tenant]++
+		}
+	}
+	if ran["gold"] < 29 || ran["gold"] > 31 {
+		t.Errorf("got %v, want gold to get three times the worker time", ran)
+	}
+}
+
+func TestFairWorkerTime(t *testing.T) {
+	// slow's jobs take three times as long so it gets a third of the jobs through
+	b := fairBalancer(1, &Fair{})
+	submit(b, "slow", 30, nil)
+	submit(b, "fast", 30, nil)
+	took := func(tenant string) time.Duration {
+		if tenant == "slow" {
+			return 3 * time.Millisecond
+		}
+		return time.Millisecond
+	}
+
+	var used [2]time.Duration
+	for i := 0; i < 40; i++ {
+		for _, tenant := range runFair(b, took) {
+			if tenant == "slow" {
+				used[0] += took(tenant)
+			} else {
+				used[1] += took(tenant)
+			}
+		}
+	}
+	if d := used[0] - used[1]; d < -3*time.Millisecond || d > 3*time.Millisecond {
+		t.Errorf("slow used %v and fast %v of worker time", used[0], used[1])
+	}
+}
+
+func TestFairMaxRunning(t *testing.T) {
+	b := fairBalancer(3, &Fair{MaxRunning: 2, Running: map[string]int{"solo": 1}})
+	submit(b, "solo", 5, nil)
+	submit(b, "pair", 5, nil)
+
+	for i := 0; i < 5; i++ {
+		running := make(map[string]int)
+		for _, tenant := range runFair(b, millisecond) {
+			running[tenant]++
+		}
+		if running["solo"] > 1 || running["pair"] > 2 {
+			t.Fatalf("got %v running at once", running)
+		}
+	}
+}
+
+func TestFairMaxQueued(t *testing.T) {
+	b := fairBalancer(1, &Fair{MaxQueued: 2})
+	result := make(chan Result, 4)
+	// one runs, two wait and the last one is turned away
+	submit(b, "a", 4, result)
+	select {
+	case r := <-result:
+		if r.Err != ErrTenantFull {
+			t.Errorf("got %v, want ErrTenantFull", r.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request over the limit wasn't rejected")
+	}
+	if b.waiting() != 2 {
+		t.Errorf("got %d waiting, want 2", b.waiting())
+	}
+}
+
+func TestFairMaxQueuedBulkhead(t *testing.T) {
+	b := fairBalancer(1, &Fair{MaxQueued: 1})
+	b.Types = map[string]Isolation{"x": {MaxRunning: 10}}
+	result := make(chan Result, 3)
+	for i := 0; i < 3; i++ {
+		b.dispatch(NewRequest(ok, result, WithTenant("a"), WithType("x")))
+	}
+	if r := <-result; r.Err != ErrTenantFull {
+		t.Fatalf("got %v, want ErrTenantFull", r.Err)
+	}
+	// the request turned away gave its place in the bulkhead back
+	if n := b.isolated["x"].running; n != 2 {
+		t.Errorf("got %d in the bulkhead, want 2", n)
+	}
+}
@@ -0,0 +1,81 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"context"
+)
+
+// Hooks let every worker hold on to a resource of its own, a connection or a
+// scratch buffer, which its jobs get through WorkerState. All of them run on
+// the worker's goroutine so the state never needs locking
+type Hooks struct {
+	// OnStart creates the state of a worker before its first job. A worker
+	// which couldn't start fails the job with the error and is replaced
+	OnStart 	func(id int) (interface{}, error)
+	// OnStop cleans up after a worker which started, once it's retired
+	// by the Scaler or replaced
+	OnStop 		func(id int, state interface{})
+	// Check runs after every job, a worker whose state is no longer healthy
+	// is replaced by a new one which takes over its queue
+	Check 		func(id int, state interface{}) error
+}
+
+type stateKey struct{}
+
+// WorkerState returns the state OnStart created for the worker running the
+// job, or nil when there are no Hooks
+func WorkerState(ctx context.Context) interface{} {
+	return ctx.Value(stateKey{})
+}
+
+// start runs OnStart the first time the worker gets a job
+func (w *Worker) start() {
+	if w.started || w.hooks == nil || w.hooks.OnStart == nil {
+		return
+	}
+	w.state, w.broken = w.hooks.OnStart(w.id)
+	w.started = w.broken == nil
+}
+
+// stop runs OnStop when the worker exits, if it ever started
+func (w *Worker) stop() {
+	if w.started && w.hooks.OnStop != nil {
+		w.hooks.OnStop(w.id, w.state)
+	}
+}
+
+// healthy tells whether the worker can take another job
+func (w *Worker) healthy() bool {
+	if w.broken != nil {
+		return false
+	}
+	if w.hooks == nil || w.hooks.Check == nil {
+		return true
+	}
+	return w.hooks.Check(w.id, w.state) == nil
+}
+
+// spawn starts a new worker for the Pool, with the Hooks of the Balancer
+func (b *Balancer) spawn() *Worker {
+	w := newWorker(b.Done)
+	w.hooks = b.Hooks
+	w.idle = b.clock().Now()
+	return w
+}
+
+// replace swaps an unhealthy worker for a new one, which takes over its
+// place in the Pool, its queue and its child process if any. The old one stops once it sees quit
+func (b *Balancer) replace(old *Worker) *Worker {
+	w := b.spawn()
+	w.queue, old.queue = old.queue, nil
+	w.pending, w.capacity, w.index = old.pending, old.capacity, old.index
+	// the child process of a process worker carries on
+	if old.proc != nil {
+		w.proc, old.proc = old.proc, nil
+	}
+	(*b.Pool)[w.index] = w
+	heap.Fix(b.Pool, w.index)
+	close(old.quit)
+	b.metrics().WorkerRemoved(old.id)
+	return w
+}
@@ -0,0 +1,136 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// counter is the state of a worker in these tests, it counts the jobs
+type counter struct {
+	id 		int
+	jobs 	int
+}
+
+func count(ctx context.Context) (int, error) {
+	c := WorkerState(ctx).(*counter)
+	c.jobs++
+	return c.id, nil
+}
+
+func TestWorkerState(t *testing.T) {
+	var (
+		mu 		sync.Mutex
+		started = make(map[int]bool)
+	)
+	requests := startBalancer(t, 2, &Balancer{Hooks: &Hooks{
+		OnStart: func(id int) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if started[id] {
+				t.Errorf("worker %d started twice", id)
+			}
+			started[id] = true
+			return &counter{id: id}, nil
+		},
+	}})
+
+	result := make(chan Result)
+	for i := 0; i < 10; i++ {
+		requests <- NewRequest(count, result)
+		r := <-result
+		mu.Lock()
+		if r.Err != nil || !started[r.Value] {
+			t.Errorf("got %+v from a worker which didn't start", r)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestUnhealthyWorker(t *testing.T) {
+	var (
+		mu 		sync.Mutex
+		starts 	int
+		stopped []*counter
+	)
+	stop := make(chan struct{})
+	requests := startBalancer(t, 1, &Balancer{Hooks: &Hooks{
+		OnStart: func(id int) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			starts++
+			return &counter{id: id}, nil
+		},
+		OnStop: func(id int, state interface{}) {
+			mu.Lock()
+			stopped = append(stopped, state.(*counter))
+			mu.Unlock()
+			stop <- struct{}{}
+		},
+		// a worker wears out after 3 jobs
+		Check: func(id int, state interface{}) error {
+			if state.(*counter).jobs >= 3 {
+				return errors.New("worn out")
+			}
+			return nil
+		},
+	}})
+
+	result := make(chan Result)
+	ids := make(map[int]bool)
+	for i := 0; i < 5; i++ {
+		requests <- NewRequest(count, result)
+		r := <-result
+		if r.Err != nil {
+			t.Fatalf("job %d failed: %v", i, r.Err)
+		}
+		ids[r.Value] = true
+	}
+	<-stop
+
+	mu.Lock()
+	defer mu.Unlock()
+	if starts != 2 || len(ids) != 2 {
+		t.Errorf("got %d starts on %d workers, want the worker replaced once", starts, len(ids))
+	}
+	if len(stopped) != 1 || stopped[0].jobs != 3 {
+		t.Errorf("got %d stops, want the worn out worker stopped", len(stopped))
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	var (
+		mu 		sync.Mutex
+		failed 	bool
+	)
+	broken := errors.New("no connection")
+	requests := startBalancer(t, 1, &Balancer{Hooks: &Hooks{
+		OnStart: func(id int) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !failed {
+				failed = true
+				return nil, broken
+			}
+			return &counter{id: id}, nil
+		},
+		OnStop: func(id int, state interface{}) {
+			// the new worker is stopped once the test is over
+			if state == nil {
+				t.Errorf("stopped a worker which never started")
+			}
+		},
+	}})
+
+	result := make(chan Result)
+	requests <- NewRequest(count, result)
+	if r := <-result; r.Err != broken {
+		t.Fatalf("got %v, want the OnStart error", r.Err)
+	}
+	requests <- NewRequest(count, result)
+	if r := <-result; r.Err != nil {
+		t.Fatalf("got %v from the new worker", r.Err)
+	}
+}
+
@@ -0,0 +1,231 @@
+package Workerpool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrJournalClosed is returned when submitting to a Journal which was closed
+var ErrJournalClosed = errors.New("Workerpool: journal closed")
+
+// record is a line of the journal
+type record struct {
+	Op 		string	`json:"op"`	// "submit" or "ack"
+	ID 		uint64	`json:"id"`
+	Type 	string	`json:"type,omitempty"`
+	Payload []byte	`json:"payload,omitempty"`
+}
+
+// Journal sits in front of a Balancer and writes every task down in an append
+// only file before submitting it, then acknowledges it once it's done. Tasks
+// which were never acknowledged, because the process went down, are submitted
+// again by Replay, so every task runs at least once
+type Journal struct {
+	registry 	*Registry
+	requests 	chan<- Request
+
+	mu 			sync.Mutex
+	f 			*os.File
+	lastID 		uint64
+	pending 	map[uint64]record	// submitted but not acknowledged yet
+	replay 		[]record			// found unacknowledged when opened
+}
+
+// OpenJournal opens or creates the journal at path, tasks are submitted to
+// requests and are run by the functions in registry
+func OpenJournal(path string, registry *Registry, requests chan<- Request) (*Journal, error) {
+	j := &Journal{
+		registry: 	registry,
+		requests: 	requests,
+		pending: 	make(map[uint64]record),
+	}
+	if err := j.load(path); err != nil {
+		return nil, err
+	}
+	if err := j.compact(path); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load reads the records in path, keeping the tasks which weren't acknowledged
+func (j *Journal) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64<<20)
+	for s.Scan() {
+		var r record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			// the last line may be half written if we went down while writing it,
+			// anything before that line is still good
+			break
+		}
+		if r.ID > j.lastID {
+			j.lastID = r.ID
+		}
+		switch r.Op {
+		case "submit":
+			j.pending[r.ID] = r
+		case "ack":
+			delete(j.pending, r.ID)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range j.pending {
+		j.replay = append(j.replay, r)
+	}
+	sort.Slice(j.replay, func(a, b int) bool { return j.replay[a].ID < j.replay[b].ID })
+	return nil
+}
+
+// compact rewrites the journal with only the tasks left to do, so it doesn't
+// grow forever, and opens it for appending
+func (j *Journal) compact(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range j.replay {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	j.f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+// write appends r to the journal and waits for it to be on disk
+func (j *Journal) write(r record) error {
+	if j.f == nil {
+		return ErrJournalClosed
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Submit records a task of type typ and submits it, the result is sent on
+// result once the task is acknowledged. It returns the id of the task
+func (j *Journal) Submit(typ string, payload []byte, result chan Result, opts ...RequestOption) (uint64, error) {
+	job, err := j.registry.Job(typ, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	j.lastID++
+	r := record{Op: "submit", ID: j.lastID, Type: typ, Payload: payload}
+	err = j.write(r)
+	if err == nil {
+		j.pending[r.ID] = r
+	}
+	j.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	j.submit(r.ID, r.Type, r.Payload, job, result, opts)
+	return r.ID, nil
+}
+
+// Replay submits the tasks found unacknowledged when the journal was opened,
+// sending their results on result if it's not nil. Tasks whose type isn't
+// registered are left in the journal and the first such error is returned
+func (j *Journal) Replay(result chan Result, opts ...RequestOption) (int, error) {
+	j.mu.Lock()
+	replay := j.replay
+	j.replay = nil
+	j.mu.Unlock()
+
+	var firstErr error
+	n := 0
+	for _, r := range replay {
+		job, err := j.registry.Job(r.Type, r.Payload)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		j.submit(r.ID, r.Type, r.Payload, job, result, opts)
+		n++
+	}
+	return n, firstErr
+}
+
+// submit sends the task to the Balancer and acknowledges it once it's done
+func (j *Journal) submit(id uint64, typ string, payload []byte, job Job, result chan Result, opts []RequestOption) {
+	done := make(chan Result, 1)
+	j.requests <- NewRequest(job, done, append([]RequestOption{WithType(typ), WithPayload(payload)}, opts...)...)
+	go func() {
+		res := <-done
+		j.mu.Lock()
+		// if this fails the task runs again after a restart, which at
+		// least once allows for
+		if j.write(record{Op: "ack", ID: id}) == nil {
+			delete(j.pending, id)
+		}
+		j.mu.Unlock()
+		if result != nil {
+			result <- res
+		}
+	}()
+}
+
+// Pending returns the number of tasks which haven't been acknowledged yet
+func (j *Journal) Pending() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.pending)
+}
+
+// Close closes the file, tasks still running won't be acknowledged and
+// will be replayed next time
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return ErrJournalClosed
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package Workerpool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func parse(_ context.Context, payload []byte) (int, error) {
+	return strconv.Atoi(string(payload))
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	registry := NewRegistry()
+	registry.Register("parse", parse)
+
+	// a pool which takes requests and never gets to them, like one going down
+	stuck := make(chan Request, 10)
+	j, err := OpenJournal(path, registry, stuck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan Result, 10)
+	for _, payload := range []string{"1", "2", "3"} {
+		if _, err := j.Submit("parse", []byte(payload), result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := j.Submit("unknown", nil, result); err == nil {
+		t.Error("submitted a task of an unknown type")
+	}
+
+	// the first one gets done before going down
+	r := <-stuck
+	v, err := r.job(r.ctx)
+	r.result <- Result{Value: v, Err: err, Attempts: 1}
+	if res := <-result; res.Value != 1 {
+		t.Fatalf("got %+v, want 1", res)
+	}
+	if j.Pending() != 2 {
+		t.Errorf("got %d pending, want 2", j.Pending())
+	}
+	j.Close()
+
+	// and a half written line at the end
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"op":"ack","i`)
+	f.Close()
+
+	j, err = OpenJournal(path, registry, startBalancer(t, 2, &Balancer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	n, err := j.Replay(result)
+	if err != nil || n != 2 {
+		t.Fatalf("replayed %d with %v, want 2", n, err)
+	}
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += (<-result).Value
+	}
+	if sum != 5 {
+		t.Errorf("replayed tasks added up to %d, want 5", sum)
+	}
+
+	// new ids carry on after the old ones
+	id, err := j.Submit("parse", []byte("4"), result)
+	if err != nil || id != 4 {
+		t.Errorf("got id %d with %v, want 4", id, err)
+	}
+	<-result
+	if j.Pending() != 0 {
+		t.Errorf("got %d pending, want 0", j.Pending())
+	}
+}
+
+func TestJournalUnknownType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	registry := NewRegistry()
+	registry.Register("parse", parse)
+
+	j, _ := OpenJournal(path, registry, make(chan Request, 1))
+	j.Submit("parse", []byte("1"), nil)
+	j.Close()
+
+	// a task whose type went away stays in the journal
+	j, _ = OpenJournal(path, NewRegistry(), startBalancer(t, 1, &Balancer{}))
+	if n, err := j.Replay(nil); n != 0 || err == nil {
+		t.Errorf("replayed %d with %v, want an error", n, err)
+	}
+	j.Close()
+
+	j, _ = OpenJournal(path, registry, startBalancer(t, 1, &Balancer{}))
+	defer j.Close()
+	if n, err := j.Replay(nil); n != 1 || err != nil {
+		t.Errorf("replayed %d with %v, want 1", n, err)
+	}
+}
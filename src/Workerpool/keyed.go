@@ -0,0 +1,55 @@
+package Workerpool
+
+import "errors"
+
+// Requests sharing a key (see WithKey) run one at a time in the order they came
+// in. Only the oldest request of a key goes through to the workers, the others
+// wait here until it's done, retries included, so they are free to run on any
+// worker and requests with different keys still balance across the Pool
+
+// ErrKeyFull is the error of a request whose key already has
+// Balancer.KeyBacklog requests waiting
+var ErrKeyFull = errors.New("Workerpool: too many requests waiting for the key")
+
+func (b *Balancer) keyBacklog() int {
+	if b.KeyBacklog <= 0 {
+		return int(defaultSize)
+	}
+	return b.KeyBacklog
+}
+
+// serialize holds t back while another request with the same key is out
+func (b *Balancer) serialize(t *task) {
+	if t.key == "" {
+		b.isolate(t)
+		return
+	}
+	if b.keys == nil {
+		b.keys = make(map[string][]*task)
+	}
+	if waiting, out := b.keys[t.key]; out {
+		if len(waiting) >= b.keyBacklog() {
+			// it holds nothing yet
+			b.reject(t.Request, "key full", ErrKeyFull)
+			b.untrace(t, ErrKeyFull)
+			return
+		}
+		b.keys[t.key] = append(waiting, t)
+		b.keyed++
+		return
+	}
+	b.keys[t.key] = nil
+	b.isolate(t)
+}
+
+// unblock lets the next request with key through, once the one before is done
+func (b *Balancer) unblock(key string) {
+	waiting := b.keys[key]
+	if len(waiting) == 0 {
+		delete(b.keys, key)
+		return
+	}
+	b.keys[key] = waiting[1:]
+	b.keyed--
+	b.isolate(waiting[0])
+}
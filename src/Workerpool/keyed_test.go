@@ -0,0 +1,118 @@
+package Workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedOrder(t *testing.T) {
+	b := &Balancer{Steal: true}
+	requests := startBalancer(t, 4, b)
+
+	var (
+		mu 		sync.Mutex
+		running = make(map[string]int)
+		order 	= make(map[string][]int)
+	)
+	job := func(key string, i int) Job {
+		return func(context.Context) (int, error) {
+			mu.Lock()
+			running[key]++
+			if running[key] > 1 {
+				t.Errorf("two requests with key %s ran at once", key)
+			}
+			order[key] = append(order[key], i)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running[key]--
+			mu.Unlock()
+			return i, nil
+		}
+	}
+
+	const n = 20
+	result := make(chan Result, 3*n)
+	for i := 0; i < n; i++ {
+		for _, key := range []string{"a", "b", "c"} {
+			// priorities would reorder them if it wasn't for the key
+			requests <- NewRequest(job(key, i), result, WithKey(key), WithPriority(i))
+		}
+	}
+	for i := 0; i < 3*n; i++ {
+		<-result
+	}
+
+	for key, seq := range order {
+		for i, v := range seq {
+			if v != i {
+				t.Fatalf("key %s ran in order %v", key, seq)
+			}
+		}
+	}
+}
+
+func TestKeysInParallel(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	// each waits for the other, so they only finish if they run at the same time
+	a, b := make(chan struct{}), make(chan struct{})
+	wait := func(mine, other chan struct{}) Job {
+		return func(context.Context) (int, error) {
+			close(mine)
+			select {
+			case <-other:
+				return 1, nil
+			case <-time.After(5 * time.Second):
+				return 0, fmt.Errorf("timed out")
+			}
+		}
+	}
+	result := make(chan Result, 2)
+	requests <- NewRequest(wait(a, b), result, WithKey("a"))
+	requests <- NewRequest(wait(b, a), result, WithKey("b"))
+	for i := 0; i < 2; i++ {
+		if r := <-result; r.Err != nil {
+			t.Fatal("different keys didn't run in parallel")
+		}
+	}
+}
+
+func TestKeyBacklog(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	gate := make(chan struct{})
+	defer close(gate)
+	blocked := func(context.Context) (int, error) {
+		<-gate
+		return 1, nil
+	}
+	hot := make(chan Result, 40)
+	requests <- NewRequest(blocked, hot, WithKey("hot"))
+	for i := 0; i < 40; i++ {
+		requests <- NewRequest(ok, hot, WithKey("hot"))
+	}
+	// 30 wait behind the first one, the others are turned away
+	for i := 0; i < 10; i++ {
+		if r := <-hot; r.Err != ErrKeyFull {
+			t.Fatalf("got %v, want ErrKeyFull", r.Err)
+		}
+	}
+
+	// and the backlog doesn't keep the other keys out
+	cold := make(chan Result, 1)
+	requests <- NewRequest(ok, cold, WithKey("cold"))
+	select {
+	case r := <-cold:
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the other key didn't get in")
+	}
+}
@@ -0,0 +1,185 @@
+package Workerpool
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrRateLimited is the error of a request whose tenant already has
+// RateLimit.MaxHeld requests held back
+var ErrRateLimited = errors.New("Workerpool: too many requests held back for the tenant")
+
+// Rate is a token bucket: requests are let through while there are tokens left,
+// Burst tokens at most, refilled at PerSecond tokens a second
+type Rate struct {
+	PerSecond 	float64		// unlimited when 0
+	Burst 		int			// at least 1
+}
+
+// RateLimit limits how fast the Balancer dispatches requests. Requests over the
+// limit are held back, up to MaxHeld for each tenant, and go out in order once
+// there are tokens. A request has to get a token for its tenant (see
+// WithTenant) as well as one for the pool
+type RateLimit struct {
+	Pool 		Rate			// limit for all the requests together
+	Tenant 		Rate			// limit for each tenant not in Tenants
+	Tenants 	map[string]Rate	// limits for specific tenants
+	MaxHeld 	int				// requests a tenant can have held back, more are rejected, defaults to 30
+}
+
+func (r *RateLimit) maxHeld() int {
+	if r.MaxHeld <= 0 {
+		return int(defaultSize)
+	}
+	return r.MaxHeld
+}
+
+type bucket struct {
+	rate 	Rate
+	tokens 	float64
+	last 	time.Time
+}
+
+// newBucket returns a full bucket for r, or nil if r is unlimited
+func newBucket(r Rate, now time.Time) *bucket {
+	if r.PerSecond <= 0 {
+		return nil
+	}
+	if r.Burst < 1 {
+		r.Burst = 1
+	}
+	return &bucket{rate: r, tokens: float64(r.Burst), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b == nil {
+		return
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.rate.Burst), b.tokens+elapsed.Seconds()*b.rate.PerSecond)
+		b.last = now
+	}
+}
+
+// wait returns how long until there's a token, 0 if there's one already
+func (b *bucket) wait() time.Duration {
+	if b == nil || b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate.PerSecond * float64(time.Second)))
+}
+
+func (b *bucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
+
+// limiter holds back the tasks over the RateLimit, a queue per tenant so a
+// tenant over its own limit doesn't hold up the others
+type limiter struct {
+	pool 		*bucket
+	tenants 	map[string]*bucket
+	held 		map[string][]*task
+	order 		[]string	// tenants with held tasks, served in turn
+	n 			int			// held tasks
+}
+
+func (l *limiter) bucket(limit *RateLimit, tenant string, now time.Time) *bucket {
+	b, ok := l.tenants[tenant]
+	if !ok {
+		r, ok := limit.Tenants[tenant]
+		if !ok {
+			r = limit.Tenant
+		}
+		b = newBucket(r, now)
+		l.tenants[tenant] = b
+	}
+	return b
+}
+
+// admit dispatches t if the rate limit allows for it and holds it back otherwise
+func (b *Balancer) admit(t *task) {
+	if b.Limit == nil {
+		b.share(t)
+		return
+	}
+	if b.limiter == nil {
+		b.limiter = &limiter{
+			pool: 		newBucket(b.Limit.Pool, b.clock().Now()),
+			tenants: 	make(map[string]*bucket),
+			held: 		make(map[string][]*task),
+		}
+	}
+
+	l := b.limiter
+	if len(l.held[t.tenant]) >= b.Limit.maxHeld() {
+		b.abandon(t, "rate limited", ErrRateLimited)
+		return
+	}
+	if len(l.held[t.tenant]) == 0 {
+		l.order = append(l.order, t.tenant)
+	}
+	l.held[t.tenant] = append(l.held[t.tenant], t)
+	l.n++
+	b.release()
+}
+
+// release dispatches the held tasks there are tokens for, going round the
+// tenants one task at a time, and sets wake for when the next token is due
+func (b *Balancer) release() {
+	l := b.limiter
+	now := b.clock().Now()
+	l.pool.refill(now)
+
+	for progress := true; progress; {
+		progress = false
+		for i := 0; i < len(l.order); i++ {
+			tenant := l.order[i]
+			tb := l.bucket(b.Limit, tenant, now)
+			tb.refill(now)
+			if l.pool.wait() > 0 || tb.wait() > 0 {
+				continue
+			}
+			l.pool.take()
+			tb.take()
+
+			t := l.held[tenant][0]
+			l.held[tenant] = l.held[tenant][1:]
+			l.n--
+			if len(l.held[tenant]) == 0 {
+				delete(l.held, tenant)
+				l.order = append(l.order[:i], l.order[i+1:]...)
+				i--
+			}
+			t.enqueued = b.clock().Now()
+			b.share(t)
+			progress = true
+		}
+	}
+
+	b.wake = nil
+	if l.n == 0 {
+		return
+	}
+	wait := time.Duration(math.MaxInt64)
+	for _, tenant := range l.order {
+		d := l.bucket(b.Limit, tenant, now).wait()
+		if pd := l.pool.wait(); pd > d {
+			d = pd
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+	b.wake = b.clock().After(wait)
+}
+
+// held returns the number of tasks held back by the rate limit
+func (b *Balancer) held() int {
+	if b.limiter == nil {
+		return 0
+	}
+	return b.limiter.n
+}
@@ -0,0 +1,133 @@
+package Workerpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Rate{PerSecond: 10, Burst: 5}, now)
+	for i := 0; i < 5; i++ {
+		if b.wait() != 0 {
+			t.Fatalf("no token for request %d within the burst", i)
+		}
+		b.take()
+	}
+	if d := b.wait(); d != 100*time.Millisecond {
+		t.Errorf("got wait %v, want 100ms", d)
+	}
+
+	b.refill(now.Add(50 * time.Millisecond))
+	if d := b.wait(); d != 50*time.Millisecond {
+		t.Errorf("got wait %v, want 50ms", d)
+	}
+
+	// tokens don't pile up past the burst
+	b.refill(now.Add(time.Hour))
+	if b.tokens != 5 {
+		t.Errorf("got %v tokens, want 5", b.tokens)
+	}
+
+	if newBucket(Rate{}, now) != nil {
+		t.Errorf("a zero rate should be unlimited")
+	}
+}
+
+// expect waits for n results and makes sure no more come in after that
+func expect(t *testing.T, result <-chan Result, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-result:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d results, want %d", i, n)
+		}
+	}
+	select {
+	case <-result:
+		t.Fatalf("got more than %d results", n)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func ok(context.Context) (int, error) {
+	return 1, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	b := &Balancer{
+		Clock: 	clock,
+		Limit: 	&RateLimit{Pool: Rate{PerSecond: 10, Burst: 2}},
+	}
+	requests := startBalancer(t, 2, b)
+
+	result := make(chan Result, 10)
+	for i := 0; i < 6; i++ {
+		requests <- NewRequest(ok, result)
+	}
+	// the burst goes straight through, then one every 100ms
+	expect(t, result, 2)
+	clock.Advance(100 * time.Millisecond)
+	expect(t, result, 1)
+	clock.Advance(50 * time.Millisecond)
+	expect(t, result, 0)
+	clock.Advance(150 * time.Millisecond)
+	expect(t, result, 2)
+	clock.Advance(time.Second)
+	expect(t, result, 1)
+}
+
+func TestTenantRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	b := &Balancer{
+		Clock: 	clock,
+		Limit: 	&RateLimit{
+			Tenant: 	Rate{PerSecond: 1, Burst: 1},
+			Tenants: 	map[string]Rate{"vip": {}},
+		},
+	}
+	requests := startBalancer(t, 2, b)
+
+	limited := make(chan Result, 10)
+	vip := make(chan Result, 10)
+	for i := 0; i < 3; i++ {
+		requests <- NewRequest(ok, limited, WithTenant("a"))
+		requests <- NewRequest(ok, vip, WithTenant("vip"))
+	}
+	// tenant a being held back doesn't hold up vip
+	expect(t, vip, 3)
+	expect(t, limited, 1)
+	clock.Advance(time.Second)
+	expect(t, limited, 1)
+}
+
+func TestRateLimitMaxHeld(t *testing.T) {
+	clock := newFakeClock()
+	b := &Balancer{
+		Clock: 	clock,
+		Limit: 	&RateLimit{Tenants: map[string]Rate{"slow": {PerSecond: 0.001}}},
+	}
+	requests := startBalancer(t, 1, b)
+
+	// one goes through, 30 are held back and the others are turned away
+	slow := make(chan Result, 40)
+	for i := 0; i < 40; i++ {
+		requests <- NewRequest(ok, slow, WithTenant("slow"))
+	}
+	for i := 0; i < 10; i++ {
+		if r := <-slow; r.Err != nil && r.Err != ErrRateLimited {
+			t.Fatalf("got %v, want ErrRateLimited", r.Err)
+		}
+	}
+	expect(t, slow, 0)
+
+	// which doesn't keep the other tenants out
+	other := make(chan Result, 1)
+	requests <- NewRequest(ok, other, WithTenant("other"))
+	if r := <-other; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+}
@@ -0,0 +1,211 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// MapReduce runs a map-reduce over a dataset in memory, eg counting words:
+// 	m := &MapReduce{
+// 		Map: func(ctx context.Context, line interface{}, emit func(string, interface{})) error {
+// 			for _, w := range strings.Fields(line.(string)) {
+// 				emit(w, 1)
+// 			}
+// 			return nil
+// 		},
+// 		Combine: func(word string, a, b interface{}) interface{} { return a.(int) + b.(int) },
+// 	}
+// 	counts, err := m.Run(ctx, requests, lines)
+// The inputs are split in chunks and every chunk is mapped by a request of its
+// own. What a chunk emitted is combined by key, then shuffled into partitions
+// which are reduced by a request each
+type MapReduce struct {
+	// Map emits the key value pairs of an input, it's called for every
+	// input of a chunk in turn
+	Map 		func(ctx context.Context, input interface{}, emit func(key string, value interface{})) error
+	// Combine merges two values of a key. It's applied to what every chunk
+	// emitted before the shuffle, so there's less to shuffle, and reduces
+	// the values when there's no Reduce
+	Combine 	func(key string, a, b interface{}) interface{}
+	// Reduce turns all the values of a key into its result, the values of
+	// a key come in no particular order
+	Reduce 		func(ctx context.Context, key string, values []interface{}) (interface{}, error)
+	ChunkSize 	int				// inputs per map request, defaults to 1024
+	Partitions 	int				// reduce requests, defaults to 8
+	// Progress is told every time a chunk is mapped or a partition
+	// reduced, one call at a time
+	Progress 	func(Progress)
+	Options 	[]RequestOption	// apply to every request
+}
+
+// ErrNoReduce is the error of a MapReduce without a Reduce or a Combine
+var ErrNoReduce = errors.New("Workerpool: map-reduce needs a Reduce or a Combine")
+
+func (m *MapReduce) chunkSize() int {
+	if m.ChunkSize <= 0 {
+		return 1024
+	}
+	return m.ChunkSize
+}
+
+func (m *MapReduce) partitions() int {
+	if m.Partitions <= 0 {
+		return 8
+	}
+	return m.Partitions
+}
+
+// partition is a share of the keys, filled during the shuffle
+type partition struct {
+	mu 		sync.Mutex
+	values 	map[string][]interface{}
+	results map[string]interface{}
+}
+
+// Run maps and reduces inputs on the Balancer taking requests, and returns the
+// result of every key. The first error, or ctx being done, stops everything
+// and is returned
+func (m *MapReduce) Run(ctx context.Context, requests chan<- Request, inputs []interface{}) (map[string]interface{}, error) {
+	if m.Reduce == nil && m.Combine == nil {
+		return nil, ErrNoReduce
+	}
+	size := m.chunkSize()
+	chunks := (len(inputs) + size - 1) / size
+	parts := make([]*partition, m.partitions())
+	for i := range parts {
+		parts[i] = &partition{values: make(map[string][]interface{})}
+	}
+
+	var mu sync.Mutex
+	progress := Progress{Total: chunks + len(parts)}
+	step := func() {
+		if m.Progress == nil {
+			return
+		}
+		mu.Lock()
+		progress.Done++
+		m.Progress(progress)
+		mu.Unlock()
+	}
+
+	g, _ := NewGroup(ctx, requests, m.Options...)
+	for start := 0; start < len(inputs); start += size {
+		end := start + size
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		chunk := inputs[start:end]
+		g.Go(func(ctx context.Context) (int, error) {
+			n, err := m.mapChunk(ctx, chunk, parts)
+			if err == nil {
+				step()
+			}
+			return n, err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	g, _ = NewGroup(ctx, requests, m.Options...)
+	for _, p := range parts {
+		p := p
+		g.Go(func(ctx context.Context) (int, error) {
+			err := m.reduce(ctx, p)
+			if err == nil {
+				step()
+			}
+			return len(p.results), err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{})
+	for _, p := range parts {
+		for key, v := range p.results {
+			results[key] = v
+		}
+	}
+	return results, nil
+}
+
+// mapChunk maps the inputs of a chunk, combines what they emitted and shuffles
+// it into the partitions. It returns how many pairs were emitted
+func (m *MapReduce) mapChunk(ctx context.Context, chunk []interface{}, parts []*partition) (int, error) {
+	var (
+		n 			int
+		combined 	= make(map[string]interface{})
+		emitted 	= make(map[string][]interface{})
+	)
+	emit := func(key string, value interface{}) {
+		n++
+		if m.Combine == nil {
+			emitted[key] = append(emitted[key], value)
+			return
+		}
+		if v, ok := combined[key]; ok {
+			value = m.Combine(key, v, value)
+		}
+		combined[key] = value
+	}
+	for _, input := range chunk {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if err := m.Map(ctx, input, emit); err != nil {
+			return n, err
+		}
+	}
+
+	for key, v := range combined {
+		emitted[key] = []interface{}{v}
+	}
+	for key, values := range emitted {
+		p := parts[shard(key, len(parts))]
+		p.mu.Lock()
+		p.values[key] = append(p.values[key], values...)
+		p.mu.Unlock()
+	}
+	return n, nil
+}
+
+// reduce turns the values of every key of a partition into its result
+func (m *MapReduce) reduce(ctx context.Context, p *partition) error {
+	p.results = make(map[string]interface{}, len(p.values))
+	for key, values := range p.values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if m.Reduce != nil {
+			v, err := m.Reduce(ctx, key, values)
+			if err != nil {
+				return err
+			}
+			p.results[key] = v
+			continue
+		}
+		v := values[0]
+		for _, w := range values[1:] {
+			v = m.Combine(key, v, w)
+		}
+		p.results[key] = v
+	}
+	return nil
+}
+
+// shard returns the partition of key
+func shard(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
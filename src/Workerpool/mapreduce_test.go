@@ -0,0 +1,148 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var corpus = []interface{}{
+	"the quick brown fox",
+	"jumps over the lazy dog",
+	"the dog sleeps",
+	"a fox and a dog",
+	"the end",
+}
+
+func words(ctx context.Context, line interface{}, emit func(string, interface{})) error {
+	for _, w := range strings.Fields(line.(string)) {
+		emit(w, 1)
+	}
+	return nil
+}
+
+func add(key string, a, b interface{}) interface{} {
+	return a.(int) + b.(int)
+}
+
+func TestMapReduce(t *testing.T) {
+	requests := startBalancer(t, 4, &Balancer{})
+
+	var (
+		mu 		sync.Mutex
+		seen 	[]Progress
+	)
+	m := &MapReduce{
+		Map: 		words,
+		Combine: 	add,
+		ChunkSize: 	2,
+		Partitions: 3,
+		Progress: 	func(p Progress) {
+			mu.Lock()
+			seen = append(seen, p)
+			mu.Unlock()
+		},
+	}
+	counts, err := m.Run(context.Background(), requests, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"the": 4, "dog": 3, "fox": 2, "a": 2, "quick": 1, "end": 1}
+	for w, n := range want {
+		if counts[w] != n {
+			t.Errorf("got %v for %q, want %d", counts[w], w, n)
+		}
+	}
+	if len(counts) != 12 {
+		t.Errorf("got %d words, want 12", len(counts))
+	}
+
+	// 3 chunks and 3 partitions
+	if len(seen) != 6 {
+		t.Fatalf("got progress %v, want 6 steps", seen)
+	}
+	for i, p := range seen {
+		if p.Done != i+1 || p.Total != 6 {
+			t.Errorf("got progress %+v at step %d", p, i)
+		}
+	}
+}
+
+func TestMapReduceReduce(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	// without a Combine every value makes it to Reduce
+	m := &MapReduce{
+		Map: func(ctx context.Context, line interface{}, emit func(string, interface{})) error {
+			for _, w := range strings.Fields(line.(string)) {
+				emit(w[:1], w)
+			}
+			return nil
+		},
+		Reduce: func(ctx context.Context, letter string, values []interface{}) (interface{}, error) {
+			var ws []string
+			for _, v := range values {
+				ws = append(ws, v.(string))
+			}
+			sort.Strings(ws)
+			return strings.Join(ws, ","), nil
+		},
+		ChunkSize: 1,
+	}
+	byLetter, err := m.Run(context.Background(), requests, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byLetter["d"] != "dog,dog,dog" || byLetter["t"] != "the,the,the,the" || byLetter["a"] != "a,a,and" {
+		t.Errorf("got %v", byLetter)
+	}
+
+	if _, err := (&MapReduce{Map: words}).Run(context.Background(), requests, corpus); err != ErrNoReduce {
+		t.Errorf("got %v without a Reduce or a Combine, want ErrNoReduce", err)
+	}
+}
+
+func TestMapReduceErrors(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	boom := errors.New("boom")
+	m := &MapReduce{
+		Map: func(ctx context.Context, line interface{}, emit func(string, interface{})) error {
+			if strings.HasPrefix(line.(string), "a ") {
+				return boom
+			}
+			return words(ctx, line, emit)
+		},
+		Combine: 	add,
+		ChunkSize: 	1,
+	}
+	if _, err := m.Run(context.Background(), requests, corpus); err != boom {
+		t.Errorf("got %v, want the error of the map", err)
+	}
+
+	m = &MapReduce{
+		Map: 	words,
+		Reduce: func(ctx context.Context, key string, values []interface{}) (interface{}, error) {
+			return nil, boom
+		},
+	}
+	if _, err := m.Run(context.Background(), requests, corpus); err != boom {
+		t.Errorf("got %v, want the error of the reduce", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m = &MapReduce{
+		Map: func(ctx context.Context, line interface{}, emit func(string, interface{})) error {
+			cancel()
+			return words(ctx, line, emit)
+		},
+		Combine: 	add,
+		ChunkSize: 	1,
+	}
+	if _, err := m.Run(ctx, requests, corpus); err != context.Canceled {
+		t.Errorf("got %v, want the context to be canceled", err)
+	}
+}
@@ -0,0 +1,234 @@
+package Workerpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics is told about everything going on in a Balancer. It's called from the
+// Balance loop so implementations should return quickly
+type Metrics interface {
+	QueueDepth(n int)					// requests waiting for a worker
+	WorkerPending(id, pending int)		// requests queued or running on a worker
+	WorkerRemoved(id int)				// the worker was retired
+	TaskDone(wait, run time.Duration)	// time spent queued and running
+	TenantDone(tenant string, run time.Duration)	// worker time used by a tenant, see WithTenant
+	TaskPanicked()
+	TaskTimedOut()						// the job was given up on, see WithTimeout
+	TaskRejected(reason string)
+}
+
+// nopMetrics is the default and discards everything
+type nopMetrics struct{}
+
+func (nopMetrics) QueueDepth(int)                        {}
+func (nopMetrics) WorkerPending(int, int)                {}
+func (nopMetrics) WorkerRemoved(int)                     {}
+func (nopMetrics) TaskDone(time.Duration, time.Duration) {}
+func (nopMetrics) TenantDone(string, time.Duration)      {}
+func (nopMetrics) TaskPanicked()                         {}
+func (nopMetrics) TaskTimedOut()                         {}
+func (nopMetrics) TaskRejected(string)                   {}
+
+// buckets are the upper bounds of the latency histograms, in seconds
+var buckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 60}
+
+type histogram struct {
+	Counts 	[]uint64	`json:"counts"`	// one per bucket plus +Inf, not cumulative
+	Sum 	float64		`json:"sum"`
+	Count 	uint64		`json:"count"`
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(buckets)+1)
+	}
+	s := d.Seconds()
+	i := sort.SearchFloat64s(buckets, s)
+	h.Counts[i]++
+	h.Sum += s
+	h.Count++
+}
+
+// Collector is a Metrics which keeps everything in memory. It is an expvar.Var,
+// so it can be published with expvar.Publish, and an http.Handler serving the
+// Prometheus text format, eg:
+// 	c := NewCollector()
+// 	expvar.Publish("workerpool", c)
+// 	http.Handle("/metrics", c)
+type Collector struct {
+	mu 			sync.Mutex
+	queued 		int
+	pending 	map[int]int
+	completed 	uint64
+	panicked 	uint64
+	timedOut 	uint64
+	rejected 	map[string]uint64
+	tenants 	map[string]float64	// seconds of worker time
+	wait 		histogram
+	run 		histogram
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		pending: 	make(map[int]int),
+		rejected: 	make(map[string]uint64),
+		tenants: 	make(map[string]float64),
+	}
+}
+
+func (c *Collector) QueueDepth(n int) {
+	c.mu.Lock()
+	c.queued = n
+	c.mu.Unlock()
+}
+
+func (c *Collector) WorkerPending(id, pending int) {
+	c.mu.Lock()
+	c.pending[id] = pending
+	c.mu.Unlock()
+}
+
+func (c *Collector) WorkerRemoved(id int) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Collector) TaskDone(wait, run time.Duration) {
+	c.mu.Lock()
+	c.completed++
+	c.wait.observe(wait)
+	c.run.observe(run)
+	c.mu.Unlock()
+}
+
+func (c *Collector) TenantDone(tenant string, run time.Duration) {
+	c.mu.Lock()
+	c.tenants[tenant] += run.Seconds()
+	c.mu.Unlock()
+}
+
+func (c *Collector) TaskPanicked() {
+	c.mu.Lock()
+	c.panicked++
+	c.mu.Unlock()
+}
+
+func (c *Collector) TaskTimedOut() {
+	c.mu.Lock()
+	c.timedOut++
+	c.mu.Unlock()
+}
+
+func (c *Collector) TaskRejected(reason string) {
+	c.mu.Lock()
+	c.rejected[reason]++
+	c.mu.Unlock()
+}
+
+// String returns the metrics as JSON, which makes a Collector an expvar.Var
+func (c *Collector) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := make(map[string]int, len(c.pending))
+	for id, n := range c.pending {
+		pending[strconv.Itoa(id)] = n
+	}
+	b, err := json.Marshal(struct {
+		Queued 		int					`json:"queued"`
+		Pending 	map[string]int		`json:"pending"`
+		Completed 	uint64				`json:"completed"`
+		Panicked 	uint64				`json:"panicked"`
+		TimedOut 	uint64				`json:"timed_out"`
+		Rejected 	map[string]uint64	`json:"rejected"`
+		Tenants 	map[string]float64	`json:"tenant_seconds"`
+		Wait 		histogram			`json:"wait_seconds"`
+		Run 		histogram			`json:"run_seconds"`
+	}{c.queued, pending, c.completed, c.panicked, c.timedOut, c.rejected, c.tenants, c.wait, c.run})
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP workerpool_queue_depth Requests waiting for a worker.")
+	fmt.Fprintln(w, "# TYPE workerpool_queue_depth gauge")
+	fmt.Fprintf(w, "workerpool_queue_depth %d\n", c.queued)
+
+	ids := make([]int, 0, len(c.pending))
+	for id := range c.pending {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fmt.Fprintln(w, "# HELP workerpool_worker_pending Requests queued or running on a worker.")
+	fmt.Fprintln(w, "# TYPE workerpool_worker_pending gauge")
+	for _, id := range ids {
+		fmt.Fprintf(w, "workerpool_worker_pending{worker=\"%d\"} %d\n", id, c.pending[id])
+	}
+
+	fmt.Fprintln(w, "# HELP workerpool_tasks_completed_total Requests which ran to completion.")
+	fmt.Fprintln(w, "# TYPE workerpool_tasks_completed_total counter")
+	fmt.Fprintf(w, "workerpool_tasks_completed_total %d\n", c.completed)
+
+	fmt.Fprintln(w, "# HELP workerpool_tasks_panicked_total Requests whose job panicked.")
+	fmt.Fprintln(w, "# TYPE workerpool_tasks_panicked_total counter")
+	fmt.Fprintf(w, "workerpool_tasks_panicked_total %d\n", c.panicked)
+
+	fmt.Fprintln(w, "# HELP workerpool_tasks_timed_out_total Requests whose job was given up on past its timeout.")
+	fmt.Fprintln(w, "# TYPE workerpool_tasks_timed_out_total counter")
+	fmt.Fprintf(w, "workerpool_tasks_timed_out_total %d\n", c.timedOut)
+
+	reasons := make([]string, 0, len(c.rejected))
+	for reason := range c.rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	fmt.Fprintln(w, "# HELP workerpool_tasks_rejected_total Requests which were never run.")
+	fmt.Fprintln(w, "# TYPE workerpool_tasks_rejected_total counter")
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "workerpool_tasks_rejected_total{reason=%q} %d\n", reason, c.rejected[reason])
+	}
+
+	tenants := make([]string, 0, len(c.tenants))
+	for tenant := range c.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	fmt.Fprintln(w, "# HELP workerpool_tenant_run_seconds_total Worker time used by each tenant.")
+	fmt.Fprintln(w, "# TYPE workerpool_tenant_run_seconds_total counter")
+	for _, tenant := range tenants {
+		fmt.Fprintf(w, "workerpool_tenant_run_seconds_total{tenant=%q} %s\n", tenant, strconv.FormatFloat(c.tenants[tenant], 'g', -1, 64))
+	}
+
+	writeHistogram(w, "workerpool_task_wait_seconds", "Time requests spent queued.", &c.wait)
+	writeHistogram(w, "workerpool_task_run_seconds", "Time requests spent running.", &c.run)
+}
+
+func writeHistogram(w http.ResponseWriter, name, help string, h *histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	var cumulative uint64
+	for i, le := range buckets {
+		if h.Counts != nil {
+			cumulative += h.Counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.Count)
+}
@@ -0,0 +1,72 @@
+package Workerpool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	requests := startBalancer(t, 1, &Balancer{Metrics: c})
+
+	result := make(chan Result)
+	requests <- NewRequest(func(context.Context) (int, error) { return 1, nil }, result)
+	<-result
+	requests <- NewRequest(func(context.Context) (int, error) { panic("boom") }, result)
+	if r := <-result; r.Err == nil {
+		t.Errorf("panicking job gave %d without an error", r.Value)
+	}
+	requests <- NewRequest(nil, result)
+	if r := <-result; r.Err != ErrNoJob {
+		t.Errorf("got %v for a request without a job, want ErrNoJob", r.Err)
+	}
+	// the pool still works after a panic
+	requests <- NewRequest(func(context.Context) (int, error) { return 2, nil }, result, WithTenant("acme"))
+	<-result
+
+	// a completion is reported after the result is sent, with a single worker
+	// the next request can only run once the previous one has been counted
+	requests <- NewRequest(func(context.Context) (int, error) { return 3, nil }, result)
+	<-result
+
+	var snapshot struct {
+		Completed 	uint64				`json:"completed"`
+		Panicked 	uint64				`json:"panicked"`
+		Rejected 	map[string]uint64	`json:"rejected"`
+		Pending 	map[string]int		`json:"pending"`
+	}
+	if err := json.Unmarshal([]byte(c.String()), &snapshot); err != nil {
+		t.Fatalf("String is not valid JSON: %v", err)
+	}
+	if snapshot.Completed < 3 {
+		t.Errorf("got %d completed, want at least 3", snapshot.Completed)
+	}
+	if snapshot.Panicked != 1 {
+		t.Errorf("got %d panicked, want 1", snapshot.Panicked)
+	}
+	if snapshot.Rejected["no job"] != 1 {
+		t.Errorf("got %v rejected, want 1 without a job", snapshot.Rejected)
+	}
+	if len(snapshot.Pending) != 1 {
+		t.Errorf("got pending for %d workers, want 1", len(snapshot.Pending))
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE workerpool_tasks_completed_total counter",
+		"workerpool_tasks_panicked_total 1\n",
+		`workerpool_tasks_rejected_total{reason="no job"} 1`,
+		`workerpool_task_run_seconds_bucket{le="+Inf"}`,
+		"workerpool_task_wait_seconds_count",
+		`workerpool_tenant_run_seconds_total{tenant="acme"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics are missing %q:\n%s", want, body)
+		}
+	}
+}
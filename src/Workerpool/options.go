@@ -0,0 +1,85 @@
+package Workerpool
+
+import (
+	"context"
+	"time"
+)
+
+// RequestOption configures a Request
+type RequestOption interface {
+	apply(*Request)
+}
+
+type requestOptionFn func(*Request)
+
+func (f requestOptionFn) apply(r *Request) {
+	f(r)
+}
+
+// WithPriority lets latency sensitive requests overtake batch ones queued on the
+// same worker, the higher the priority the sooner it runs. Requests default to 0
+func WithPriority(p int) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.priority = p
+	})
+}
+
+// WithContext sets the context handed to the job. A request whose context is done
+// by the time a worker gets to it doesn't run and isn't retried
+func WithContext(ctx context.Context) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.ctx = ctx
+	})
+}
+
+// WithRetry has the pool run the job again when it fails, as the policy says
+func WithRetry(p RetryPolicy) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.retry = &p
+	})
+}
+
+// WithTenant tags the request with who it's for, so it counts against
+// that tenant's RateLimit and its share of the workers under Fair
+func WithTenant(tenant string) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.tenant = tenant
+	})
+}
+
+// WithKey makes the request run after every request with the same key that
+// came before it, and never alongside one of them. Requests with different
+// keys, or no key, run in parallel as usual. A key with Balancer.KeyBacklog
+// requests waiting already rejects the next ones with ErrKeyFull
+func WithKey(key string) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.key = key
+	})
+}
+
+// WithType tags the request with the kind of job it runs, so it's subject to
+// the bulkhead and circuit breaker in Balancer.Types for that type
+func WithType(typ string) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.typ = typ
+	})
+}
+
+// WithTimeout cancels the context of the job once it ran for d, every attempt
+// gets d of its own. A job still running Grace after that is given up on and
+// fails with ErrTimeout, see Balancer.Timeout
+func WithTimeout(d time.Duration) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.timeout = d
+	})
+}
+
+// WithPayload says the job runs the task of the request's type (see WithType)
+// with payload, so a process worker can run it in its child process instead.
+// Other workers run the job as usual
+func WithPayload(payload []byte) RequestOption {
+	return requestOptionFn(func(r *Request) {
+		r.payload = payload
+		r.remote = true
+	})
+}
@@ -0,0 +1,160 @@
+package Workerpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StageFunc turns a value coming into a stage into the one going out
+type StageFunc func(ctx context.Context, v interface{}) (interface{}, error)
+
+// Pipeline chains stages, each with a Pool of its own, eg:
+// 	p := &Pipeline{Buffer: 16, Ordered: true}
+// 	p.Stage("parse", 2, parse).Stage("enrich", 8, enrich).Stage("write", 1, write)
+// 	out, wait := p.Run(ctx, lines)
+// Stages are connected by channels of Buffer values, a slow stage holds up the
+// ones before it once they're full. The first error stops the whole pipeline
+type Pipeline struct {
+	Buffer 		int		// capacity of the channels between stages
+	Ordered 	bool	// values come out in the order they went in
+
+	stages 		[]*stage
+}
+
+type stage struct {
+	name 		string
+	workers 	int
+	fn 			StageFunc
+	opts 		[]RequestOption
+}
+
+// item is a value going through a stage
+type item struct {
+	out 	interface{}
+	result 	chan Result
+	res 	Result
+}
+
+// Stage adds a stage running fn on workers workers at once, the options
+// apply to every request of the stage
+func (p *Pipeline) Stage(name string, workers int, fn StageFunc, opts ...RequestOption) *Pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	p.stages = append(p.stages, &stage{name, workers, fn, opts})
+	return p
+}
+
+// Run starts a Balancer for every stage and feeds them what comes in on in.
+// What comes out of the last stage is sent on the returned channel, which
+// has to be read until it's closed. wait blocks until every stage is done,
+// their Pools retired, and returns the first error, or the error of ctx when
+// it was done before any stage failed
+func (p *Pipeline) Run(ctx context.Context, in <-chan interface{}) (out <-chan interface{}, wait func() error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		wg 			sync.WaitGroup
+		once 		sync.Once
+		firstErr 	error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for _, s := range p.stages {
+		next := make(chan interface{}, p.Buffer)
+		wg.Add(1)
+		go func(s *stage, in <-chan interface{}, out chan<- interface{}) {
+			defer wg.Done()
+			defer close(out)
+			s.run(ctx, in, out, p.Ordered, fail)
+		}(s, in, next)
+		in = next
+	}
+
+	return in, func() error {
+		wg.Wait()
+		if err := parent.Err(); firstErr == nil && err != nil {
+			firstErr = err
+		}
+		cancel()
+		return firstErr
+	}
+}
+
+// run runs the stage on its own Balancer until in is closed or ctx is done
+func (s *stage) run(ctx context.Context, in <-chan interface{}, out chan<- interface{}, ordered bool, fail func(error)) {
+	done := make(chan *Worker)
+	requests := make(chan Request)
+	b := &Balancer{Pool: New(s.workers, done), Done: done}
+	stopped := make(chan struct{})
+	go func() {
+		b.Balance(requests)
+		close(stopped)
+	}()
+	defer func() { <-stopped }()
+
+	// at most workers values are in the stage at once, in the order they
+	// came in when ordered
+	inflight := make(chan *item, s.workers)
+	finished := make(chan *item, s.workers)
+	go func() {
+		defer close(requests)
+		defer close(inflight)
+		for {
+			var v interface{}
+			var ok bool
+			select {
+			case v, ok = <-in:
+			case <-ctx.Done():
+			}
+			if !ok {
+				return
+			}
+
+			it := &item{result: make(chan Result, 1)}
+			job := func(ctx context.Context) (int, error) {
+				var err error
+				it.out, err = s.fn(ctx, v)
+				return 0, err
+			}
+			opts := append([]RequestOption{WithContext(ctx)}, s.opts...)
+			inflight <- it
+			requests <- NewRequest(job, it.result, opts...)
+			if !ordered {
+				go func() {
+					it.res = <-it.result
+					finished <- it
+				}()
+			}
+		}
+	}()
+
+	emit := func(it *item) {
+		if it.res.Err != nil {
+			if ctx.Err() == nil {
+				fail(fmt.Errorf("Workerpool: stage %q: %w", s.name, it.res.Err))
+			}
+			return
+		}
+		select {
+		case out <- it.out:
+		case <-ctx.Done():
+		}
+	}
+	if ordered {
+		for it := range inflight {
+			it.res = <-it.result
+			emit(it)
+		}
+		return
+	}
+	for range inflight {
+		emit(<-finished)
+	}
+}
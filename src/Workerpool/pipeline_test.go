@@ -0,0 +1,130 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func values(vs ...interface{}) <-chan interface{} {
+	in := make(chan interface{}, len(vs))
+	for _, v := range vs {
+		in <- v
+	}
+	close(in)
+	return in
+}
+
+func jitter() {
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+}
+
+func atoi(_ context.Context, v interface{}) (interface{}, error) {
+	jitter()
+	return strconv.Atoi(v.(string))
+}
+
+func square(_ context.Context, v interface{}) (interface{}, error) {
+	jitter()
+	return v.(int) * v.(int), nil
+}
+
+func format(_ context.Context, v interface{}) (interface{}, error) {
+	return fmt.Sprint("#", v), nil
+}
+
+func TestPipelineOrdered(t *testing.T) {
+	var in []interface{}
+	for i := 0; i < 50; i++ {
+		in = append(in, strconv.Itoa(i))
+	}
+	p := &Pipeline{Buffer: 4, Ordered: true}
+	p.Stage("parse", 2, atoi).Stage("square", 8, square).Stage("format", 1, format)
+	out, wait := p.Run(context.Background(), values(in...))
+
+	i := 0
+	for v := range out {
+		if want := fmt.Sprint("#", i*i); v != want {
+			t.Fatalf("got %v at %d, want %v", v, i, want)
+		}
+		i++
+	}
+	if err := wait(); err != nil || i != 50 {
+		t.Errorf("got %d values and %v", i, err)
+	}
+}
+
+func TestPipelineUnordered(t *testing.T) {
+	p := &Pipeline{}
+	p.Stage("parse", 4, atoi).Stage("square", 4, square)
+	out, wait := p.Run(context.Background(), values("1", "2", "3", "4", "5"))
+
+	sum := 0
+	for v := range out {
+		sum += v.(int)
+	}
+	if err := wait(); err != nil || sum != 55 {
+		t.Errorf("got sum %d and %v, want 55", sum, err)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	var after int32
+	p := &Pipeline{}
+	p.Stage("parse", 2, atoi).Stage("count", 1, func(_ context.Context, v interface{}) (interface{}, error) {
+		atomic.AddInt32(&after, 1)
+		return v, nil
+	})
+	var in []interface{}
+	for i := 0; i < 100; i++ {
+		in = append(in, strconv.Itoa(i))
+	}
+	in[10] = "ten"
+	out, wait := p.Run(context.Background(), values(in...))
+	for range out {
+	}
+
+	err := wait()
+	var numErr *strconv.NumError
+	if err == nil || !strings.Contains(err.Error(), `stage "parse"`) || !errors.As(err, &numErr) {
+		t.Fatalf("got %v, want the parse error", err)
+	}
+	if n := atomic.LoadInt32(&after); n >= 100 {
+		t.Errorf("all %d values went through after the error", n)
+	}
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	var parsed int32
+	gate := make(chan struct{})
+	p := &Pipeline{Buffer: 1}
+	p.Stage("parse", 1, func(ctx context.Context, v interface{}) (interface{}, error) {
+		atomic.AddInt32(&parsed, 1)
+		return v, nil
+	}).Stage("write", 1, func(ctx context.Context, v interface{}) (interface{}, error) {
+		<-gate
+		return v, nil
+	})
+
+	in := make([]interface{}, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	out, wait := p.Run(ctx, values(in...))
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&parsed); n > 10 {
+		t.Errorf("parsed %d values while the writer was stuck", n)
+	}
+
+	cancel()
+	close(gate)
+	for range out {
+	}
+	if err := wait(); err != context.Canceled {
+		t.Errorf("got %v after cancel, want context.Canceled", err)
+	}
+}
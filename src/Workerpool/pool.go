@@ -12,12 +12,30 @@ const defaultSize int32 = 30
 
 // create a new pool
 func New(workers int, done chan *Worker) *Pool {
+	capacities := make([]int, workers)
+	for w := range capacities {
+		capacities[w] = 1
+	}
+	return NewWeighted(capacities, done)
+}
+
+// NewWeighted creates a pool with one worker per capacity, a worker with
+// capacity 2 is expected to get through twice the work of one with capacity 1.
+// Capacities are only used by the Weighted strategy
+func NewWeighted(capacities []int, done chan *Worker) *Pool {
+	return weighted(capacities, func() *Worker { return newWorker(done) })
+}
+
+// weighted builds a pool out of the workers made by mk
+func weighted(capacities []int, mk func() *Worker) *Pool {
 	var p Pool
-	for w := 0; w < workers; w++ {
-		requests := make(chan Request, defaultSize)
-		worker := Worker{requests, 0, w}
-		go worker.Work(done)
-		p = append(p, &worker)
+	for w, capacity := range capacities {
+		worker := mk()
+		worker.index = w
+		if capacity > 0 {
+			worker.capacity = capacity
+		}
+		p = append(p, worker)
 	}
 	heap.Init(&p)
 	return &p
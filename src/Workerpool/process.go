@@ -0,0 +1,413 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrProcessExited is the error of a job whose child process died, or stopped
+// answering, while it ran. The child is respawned for the next job
+var ErrProcessExited = errors.New("Workerpool: worker process exited")
+
+// socketEnv tells a child process where to connect to its worker
+const socketEnv = "WORKERPOOL_SOCKET"
+
+// Process describes the child process behind a process worker. The child is
+// a program which calls ServeProcess first thing in main, eg:
+// 	func main() {
+// 		if Workerpool.ServeProcess(registry) {
+// 			return
+// 		}
+// 		...
+// 	}
+// so by default the child is the running program itself
+type Process struct {
+	Path 		string			// the program, defaults to os.Executable
+	Args 		[]string
+	Env 		[]string		// on top of the environment of the parent
+	Dir 		string
+	Heartbeat 	time.Duration	// how often the child is pinged, defaults to a second
+	Timeout 	time.Duration	// a child not heard from for this long is killed, defaults to 3 heartbeats
+	MaxJobs 	int				// the child is recycled after this many jobs, never when 0
+	// OnError, if set, is called with the error every time a child can't
+	// be respawned, it's tried again a heartbeat later. It's called from
+	// the worker's own goroutines and should return quickly
+	OnError 	func(err error)
+}
+
+func (c *Process) heartbeat() time.Duration {
+	if c.Heartbeat <= 0 {
+		return time.Second
+	}
+	return c.Heartbeat
+}
+
+func (c *Process) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 3 * c.heartbeat()
+	}
+	return c.Timeout
+}
+
+// message goes either way over the socket, one JSON object per line
+type message struct {
+	Op 		string	`json:"op"`	// run, cancel and ping from the worker, result and pong from the child
+	ID 		uint64	`json:"id,omitempty"`
+	Type 	string	`json:"type,omitempty"`
+	Payload []byte	`json:"payload,omitempty"`
+	Value 	int		`json:"value,omitempty"`
+	Error 	string	`json:"error,omitempty"`
+}
+
+// process is the child of a process worker, respawned whenever it dies
+type process struct {
+	cfg 	Process
+
+	mu 		sync.Mutex
+	conn 	net.Conn		// nil while the child is being respawned
+	enc 	*json.Encoder
+	cmd 	*exec.Cmd
+	up 		chan struct{}	// closed once a child is connected
+	seen 	time.Time		// when the child was last heard from
+	jobs 	int				// jobs the child ran
+	calls 	map[uint64]chan message
+	lastID 	uint64
+	spawns 	int
+	closed 	bool
+}
+
+// NewProcessWorker starts a worker running the tasks of its requests (see
+// WithPayload) in a child process, other jobs run in the worker as usual. It
+// waits for the child to connect, add the worker to a Pool before Balance
+// starts with Pool.Add
+func NewProcessWorker(cfg Process, done chan *Worker) (*Worker, error) {
+	p := &process{cfg: cfg, up: make(chan struct{}), calls: make(map[uint64]chan message)}
+	if err := p.spawn(); err != nil {
+		return nil, err
+	}
+	go p.heartbeat()
+
+	w := makeWorker()
+	w.proc = p
+	go w.Work(done)
+	return w, nil
+}
+
+// Add puts a worker in the Pool, it must not be called while Balance is running
+func (p *Pool) Add(w *Worker) {
+	heap.Push(p, w)
+}
+
+// spawn starts a child and waits for it to connect
+func (p *process) spawn() error {
+	path := p.cfg.Path
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		path = exe
+	}
+	dir, err := os.MkdirTemp("", "workerpool")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "worker.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	cmd := exec.Command(path, p.cfg.Args...)
+	cmd.Env = append(append(os.Environ(), p.cfg.Env...), socketEnv+"="+socket)
+	cmd.Dir = p.cfg.Dir
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ln.SetDeadline(time.Now().Add(10 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("Workerpool: worker process didn't connect: %w", err)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		conn.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil
+	}
+	p.conn, p.enc, p.cmd = conn, json.NewEncoder(conn), cmd
+	p.seen, p.jobs = time.Now(), 0
+	p.spawns++
+	close(p.up)
+	p.mu.Unlock()
+	go p.read(conn, cmd)
+	return nil
+}
+
+// read takes the messages of the child until it goes away, then fails the
+// jobs it was running and respawns it
+func (p *process) read(conn net.Conn, cmd *exec.Cmd) {
+	dec := json.NewDecoder(conn)
+	for {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			break
+		}
+		p.mu.Lock()
+		p.seen = time.Now()
+		if m.Op == "result" {
+			if c, ok := p.calls[m.ID]; ok {
+				delete(p.calls, m.ID)
+				c <- m
+			}
+			p.jobs++
+			if p.cfg.MaxJobs > 0 && p.jobs >= p.cfg.MaxJobs {
+				// the child exits once it sees the connection closed
+				p.drop(conn)
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	p.drop(conn)
+	for id, c := range p.calls {
+		delete(p.calls, id)
+		c <- message{Op: "exited"}
+	}
+	closed := p.closed
+	p.mu.Unlock()
+	conn.Close()
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	for !closed {
+		err := p.spawn()
+		if err == nil {
+			return
+		}
+		if p.cfg.OnError != nil {
+			p.cfg.OnError(err)
+		}
+		time.Sleep(p.cfg.heartbeat())
+		p.mu.Lock()
+		closed = p.closed
+		p.mu.Unlock()
+	}
+}
+
+// drop closes the connection to the child, new jobs wait for the next one.
+// It's called with the lock held
+func (p *process) drop(conn net.Conn) {
+	conn.Close()
+	if p.conn == conn {
+		p.conn, p.enc = nil, nil
+		p.up = make(chan struct{})
+	}
+}
+
+// heartbeat pings the child, and kills it once it's been quiet for too long
+func (p *process) heartbeat() {
+	tick := time.NewTicker(p.cfg.heartbeat())
+	defer tick.Stop()
+	for range tick.C {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		if p.conn != nil {
+			if time.Since(p.seen) > p.cfg.timeout() {
+				// read notices and takes it from there
+				p.drop(p.conn)
+			} else {
+				p.enc.Encode(message{Op: "ping"})
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// call runs a task in the child, waiting for one to be up if it's being
+// respawned
+func (p *process) call(ctx context.Context, typ string, payload []byte) (int, error) {
+	p.mu.Lock()
+	for p.conn == nil && !p.closed {
+		up := p.up
+		p.mu.Unlock()
+		select {
+		case <-up:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	if p.closed {
+		p.mu.Unlock()
+		return 0, ErrProcessExited
+	}
+	p.lastID++
+	id := p.lastID
+	c := make(chan message, 1)
+	p.calls[id] = c
+	p.enc.Encode(message{Op: "run", ID: id, Type: typ, Payload: payload})
+	p.mu.Unlock()
+
+	select {
+	case m := <-c:
+		return p.result(m, ctx)
+	case <-ctx.Done():
+	}
+	// the job should give up now, if it doesn't the watchdog kills the child
+	p.mu.Lock()
+	if p.conn != nil {
+		p.enc.Encode(message{Op: "cancel", ID: id})
+	}
+	p.mu.Unlock()
+	return p.result(<-c, ctx)
+}
+
+// result turns what came back from the child into the outcome of the job
+func (p *process) result(m message, ctx context.Context) (int, error) {
+	switch {
+	case m.Op == "exited":
+		return 0, ErrProcessExited
+	case m.Op == "timeout":
+		return 0, ErrTimeout
+	case m.Error != "" && ctx.Err() != nil:
+		return m.Value, ctx.Err()
+	case m.Error != "":
+		return m.Value, errors.New(m.Error)
+	}
+	return m.Value, nil
+}
+
+// abort kills the child, failing the jobs it was running with ErrTimeout.
+// It's called by the watchdog, see WithTimeout
+func (p *process) abort() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, c := range p.calls {
+		delete(p.calls, id)
+		c <- message{Op: "timeout"}
+	}
+	if p.conn != nil {
+		p.drop(p.conn)
+	}
+}
+
+// close kills the child for good, once the worker is retired
+func (p *process) close() {
+	p.mu.Lock()
+	p.closed = true
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.mu.Unlock()
+}
+
+// ServeProcess runs the tasks of registry for the worker which started the
+// process and returns true once the worker is gone. It returns false straight
+// away when the process wasn't started by a process worker. A process which
+// can't reach its worker returns true as well, the worker gives up on it and
+// reports it through Process.OnError
+func ServeProcess(registry *Registry) bool {
+	socket := os.Getenv(socketEnv)
+	if socket == "" {
+		return false
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return true
+	}
+	serveProcess(conn, registry)
+	return true
+}
+
+func serveProcess(conn net.Conn, registry *Registry) {
+	defer conn.Close()
+	var (
+		mu 		sync.Mutex
+		enc 	= json.NewEncoder(conn)
+		cancels = make(map[uint64]context.CancelFunc)
+	)
+	send := func(m message) {
+		mu.Lock()
+		enc.Encode(m)
+		mu.Unlock()
+	}
+	defer func() {
+		mu.Lock()
+		for _, cancel := range cancels {
+			cancel()
+		}
+		mu.Unlock()
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			return
+		}
+		switch m.Op {
+		case "ping":
+			send(message{Op: "pong"})
+		case "cancel":
+			mu.Lock()
+			if cancel, ok := cancels[m.ID]; ok {
+				cancel()
+			}
+			mu.Unlock()
+		case "run":
+			ctx, cancel := context.WithCancel(context.Background())
+			mu.Lock()
+			cancels[m.ID] = cancel
+			mu.Unlock()
+			go func(m message) {
+				v, err := runTask(ctx, registry, m.Type, m.Payload)
+				mu.Lock()
+				delete(cancels, m.ID)
+				mu.Unlock()
+				cancel()
+				res := message{Op: "result", ID: m.ID, Value: v}
+				if err != nil {
+					res.Error = err.Error()
+				}
+				send(res)
+			}(m)
+		}
+	}
+}
+
+// runTask runs a task in the child, a panic fails the task rather than
+// taking the child down
+func runTask(ctx context.Context, registry *Registry, typ string, payload []byte) (v int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = 0, fmt.Errorf("Workerpool: job panicked: %v", r)
+		}
+	}()
+	job, err := registry.Job(typ, payload)
+	if err != nil {
+		return 0, err
+	}
+	return job(ctx)
+}
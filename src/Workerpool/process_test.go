@@ -0,0 +1,259 @@
+//go:build unix
+
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// the test binary is its own child process
+func TestMain(m *testing.M) {
+	if ServeProcess(processTasks()) {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func processTasks() *Registry {
+	r := NewRegistry()
+	r.Register("pid", func(ctx context.Context, payload []byte) (int, error) {
+		return os.Getpid(), nil
+	})
+	r.Register("crash", func(ctx context.Context, payload []byte) (int, error) {
+		os.Exit(3)
+		return 0, nil
+	})
+	r.Register("sleep", func(ctx context.Context, payload []byte) (int, error) {
+		ms, _ := strconv.Atoi(string(payload))
+		select {
+		case <-time.After(time.Duration(ms) * time.Millisecond):
+			return ms, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	r.Register("hang", func(ctx context.Context, payload []byte) (int, error) {
+		select {}
+	})
+	return r
+}
+
+// startProcesses starts a Balancer with local workers and process workers
+func startProcesses(t *testing.T, local, procs int, cfg Process) (chan Request, []*Worker) {
+	done := make(chan *Worker)
+	requests := make(chan Request)
+	pool := New(local, done)
+	var workers []*Worker
+	for i := 0; i < procs; i++ {
+		w, err := NewProcessWorker(cfg, done)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Add(w)
+		workers = append(workers, w)
+	}
+	b := &Balancer{Pool: pool, Done: done}
+	go b.Balance(requests)
+	t.Cleanup(func() { close(requests) })
+	return requests, workers
+}
+
+func runRemote(t *testing.T, requests chan Request, typ, payload string, opts ...RequestOption) Result {
+	result := make(chan Result, 1)
+	r, err := processTasks().Request(typ, []byte(payload), result, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests <- r
+	return <-result
+}
+
+func TestProcessWorker(t *testing.T) {
+	requests, _ := startProcesses(t, 1, 2, Process{})
+
+	result := make(chan Result, 30)
+	reg := processTasks()
+	for i := 0; i < 30; i++ {
+		r, _ := reg.Request("sleep", []byte("5"), result)
+		requests <- r
+	}
+	for i := 0; i < 30; i++ {
+		if r := <-result; r.Err != nil || r.Value != 5 {
+			t.Fatalf("got %+v", r)
+		}
+	}
+
+	// the pids tell which tasks ran in a child
+	pids := make(map[int]bool)
+	for i := 0; i < 30; i++ {
+		requests <- func() Request {
+			r, _ := reg.Request("pid", nil, result)
+			return r
+		}()
+	}
+	for i := 0; i < 30; i++ {
+		r := <-result
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		pids[r.Value] = true
+	}
+	delete(pids, os.Getpid())
+	if len(pids) == 0 {
+		t.Errorf("no task ran in a child process")
+	}
+}
+
+func TestProcessLocalJob(t *testing.T) {
+	requests, _ := startProcesses(t, 0, 1, Process{})
+
+	// a job without a payload runs in the worker
+	result := make(chan Result, 1)
+	requests <- NewRequest(func(ctx context.Context) (int, error) {
+		return os.Getpid(), nil
+	}, result)
+	if r := <-result; r.Value != os.Getpid() {
+		t.Errorf("got %+v, want the pid of the test", r)
+	}
+}
+
+func TestProcessRespawn(t *testing.T) {
+	requests, workers := startProcesses(t, 0, 1, Process{})
+
+	before := runRemote(t, requests, "pid", "").Value
+	if r := runRemote(t, requests, "crash", ""); !errors.Is(r.Err, ErrProcessExited) {
+		t.Fatalf("got %+v, want ErrProcessExited", r)
+	}
+	after := runRemote(t, requests, "pid", "")
+	if after.Err != nil || after.Value == before {
+		t.Errorf("got %+v, want a new child than %d", after, before)
+	}
+	p := workers[0].proc
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.spawns != 2 {
+		t.Errorf("spawned %d children, want 2", p.spawns)
+	}
+}
+
+func TestProcessHeartbeat(t *testing.T) {
+	requests, _ := startProcesses(t, 0, 1, Process{
+		Heartbeat: 10 * time.Millisecond,
+		Timeout: 	50 * time.Millisecond,
+	})
+
+	pid := runRemote(t, requests, "pid", "").Value
+	syscall.Kill(pid, syscall.SIGSTOP)
+	start := time.Now()
+	if r := runRemote(t, requests, "sleep", "1"); !errors.Is(r.Err, ErrProcessExited) {
+		t.Fatalf("got %+v from a stopped child, want ErrProcessExited", r)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("took %v to give up on the stopped child", d)
+	}
+	if r := runRemote(t, requests, "pid", ""); r.Err != nil || r.Value == pid {
+		t.Errorf("got %+v, want a new child than %d", r, pid)
+	}
+}
+
+func TestProcessRecycle(t *testing.T) {
+	requests, _ := startProcesses(t, 0, 1, Process{MaxJobs: 2})
+
+	var pids []int
+	for i := 0; i < 5; i++ {
+		r := runRemote(t, requests, "pid", "")
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		pids = append(pids, r.Value)
+	}
+	if pids[0] != pids[1] || pids[1] == pids[2] || pids[2] != pids[3] || pids[3] == pids[4] {
+		t.Errorf("got pids %v, want a new child every 2 jobs", pids)
+	}
+}
+
+func TestProcessCancel(t *testing.T) {
+	requests, _ := startProcesses(t, 0, 1, Process{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := make(chan Result, 1)
+	r, _ := processTasks().Request("sleep", []byte("10000"), result, WithContext(ctx))
+	requests <- r
+	if res := <-result; !errors.Is(res.Err, context.DeadlineExceeded) {
+		t.Fatalf("got %+v, want the deadline", res)
+	}
+	// the child is still there for the next task
+	if res := runRemote(t, requests, "sleep", "1"); res.Err != nil {
+		t.Errorf("got %+v after a cancel", res)
+	}
+}
+
+func TestProcessTimeout(t *testing.T) {
+	done := make(chan *Worker)
+	requests := make(chan Request)
+	w, err := NewProcessWorker(Process{}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := New(0, done)
+	pool.Add(w)
+	c := NewCollector()
+	b := &Balancer{Pool: pool, Done: done, Timeout: 10 * time.Millisecond, Grace: 10 * time.Millisecond, Metrics: c}
+	go b.Balance(requests)
+	defer close(requests)
+
+	pid := runRemote(t, requests, "pid", "", WithTimeout(time.Second)).Value
+	// it isn't retried, no more than a local job which hung
+	if r := runRemote(t, requests, "hang", "", WithRetry(RetryPolicy{MaxAttempts: 3})); !errors.Is(r.Err, ErrTimeout) || r.Attempts != 1 {
+		t.Fatalf("got %+v, want ErrTimeout", r)
+	}
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "workerpool_tasks_timed_out_total 1\n") {
+		t.Errorf("metrics are missing the timeout:\n%s", body)
+	}
+	// the child was killed rather than the worker, the new one takes a
+	// while to start
+	if r := runRemote(t, requests, "pid", "", WithTimeout(time.Second)); r.Err != nil || r.Value == pid {
+		t.Errorf("got %+v, want a new child than %d", r, pid)
+	}
+	if ids := poolIDs(b); len(ids) != 1 || ids[0] != w.id {
+		t.Errorf("got workers %v, want %d", ids, w.id)
+	}
+}
+
+func TestProcessRespawnError(t *testing.T) {
+	errs := make(chan error, 1)
+	requests, workers := startProcesses(t, 0, 1, Process{
+		Heartbeat: 10 * time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+
+	// the next child can't be started
+	workers[0].proc.cfg.Path = "/nonexistent"
+	if r := runRemote(t, requests, "crash", ""); !errors.Is(r.Err, ErrProcessExited) {
+		t.Fatalf("got %+v, want ErrProcessExited", r)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v, want the program missing", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError wasn't called")
+	}
+}
@@ -0,0 +1,77 @@
+package Workerpool
+
+import (
+	"context"
+	"time"
+)
+
+// task is a Request waiting in, or handed out by, the Balancer
+type task struct {
+	Request
+	seq 		uint64			// arrival order, breaks ties between equal ranks
+	rank 		int64			// the higher the rank the sooner the task runs
+	enqueued 	time.Time
+	attempts 	int				// how many times the job ran so far
+	tries 		int				// how many times it went to a worker, kept by the Balancer
+	cost 		time.Duration	// what the tenant was charged up front, see Fair
+	trial 		bool			// let through a half-open breaker
+	tracer 		Tracer			// nil when the task isn't traced
+	trace 		context.Context	// carries the span of the task
+	span 		Span
+	phase 		Span			// the part of its life the task is in, see Tracer
+	deadline 	time.Time		// when the watchdog gives up on the running job
+	delivered 	int32			// the result was sent, see claim
+
+	// set by the worker every time it runs the job
+	elapsed 	time.Duration	// how long the job ran
+	panicked 	bool			// the job panicked
+	again 		bool			// the job failed and is going to be retried
+	unhealthy 	bool			// the worker failed its Check after running the job
+	ran 		bool			// the job ran, it doesn't when its context is done first
+	failed 		bool			// the job returned an error
+	err 		error			// what the job returned, or why it didn't run
+}
+
+// queue holds the tasks waiting for a worker, highest rank first. It
+// implements heap.Interface just like the Pool
+type queue []*task
+
+func (q queue) Less(i, j int) bool {
+	if q[i].rank != q[j].rank {
+		return q[i].rank > q[j].rank
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q queue) Len() int {
+	return len(q)
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *queue) Push(t interface{}) {
+	*q = append(*q, t.(*task))
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	*q = old[0 : n-1]
+	return t
+}
+
+// rank works out where a request goes in the queue, arrived being the time since
+// the Balancer started. Without aging the priority is all that matters, with aging
+// a request which waited for Aging is as good as one a priority higher which just
+// arrived, so batch jobs can't starve forever. Every request ages at the same rate
+// so the order of the queue never changes once a task is in
+func rank(priority int, arrived, aging time.Duration) int64 {
+	if aging <= 0 {
+		return int64(priority)
+	}
+	return int64(priority)*int64(aging) - int64(arrived)
+}
@@ -0,0 +1,74 @@
+package Workerpool
+
+import (
+	"context"
+	"container/heap"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityOrder(t *testing.T) {
+	requests := startBalancer(t, 1, &Balancer{})
+
+	type run struct{ priority, seq int }
+	var (
+		mu 		sync.Mutex
+		order 	[]run
+	)
+	record := func(r run) Job {
+		return func(context.Context) (int, error) {
+			mu.Lock()
+			order = append(order, r)
+			mu.Unlock()
+			return r.priority, nil
+		}
+	}
+
+	// keep the worker busy so everything else piles up in its queue
+	gate := make(chan struct{})
+	result := make(chan Result, defaultSize)
+	requests <- NewRequest(func(context.Context) (int, error) { <-gate; return 0, nil }, result)
+
+	n := int(defaultSize) - 1
+	for i := 0; i < n; i++ {
+		p := rand.Intn(5)
+		requests <- NewRequest(record(run{p, i}), result, WithPriority(p))
+	}
+	close(gate)
+	for i := 0; i <= n; i++ {
+		<-result
+	}
+
+	for i := 1; i < len(order); i++ {
+		prev, cur := order[i-1], order[i]
+		if cur.priority > prev.priority || (cur.priority == prev.priority && cur.seq < prev.seq) {
+			t.Fatalf("request %v ran after %v", cur, prev)
+		}
+	}
+}
+
+func TestAging(t *testing.T) {
+	const aging = time.Second
+	q := &queue{}
+	push := func(seq uint64, priority int, arrived time.Duration) {
+		heap.Push(q, &task{seq: seq, rank: rank(priority, arrived, aging)})
+	}
+
+	push(1, 0, 0)                    // a batch job which has been waiting
+	push(2, 1, 2*time.Second)        // arrived two seconds later, more than a level is worth
+	push(3, 1, 500*time.Millisecond) // arrived soon enough to overtake
+	push(4, 0, 0)                    // same as the first, goes by arrival
+
+	want := []uint64{3, 1, 4, 2}
+	for _, seq := range want {
+		if got := heap.Pop(q).(*task).seq; got != seq {
+			t.Fatalf("got task %d, want %d", got, seq)
+		}
+	}
+
+	if rank(3, time.Hour, 0) != 3 {
+		t.Errorf("rank without aging should be the priority")
+	}
+}
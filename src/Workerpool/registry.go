@@ -0,0 +1,66 @@
+package Workerpool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// TaskFunc is a job which is described by a name and a payload, so it can be
+// written down and run again later or somewhere else
+type TaskFunc func(ctx context.Context, payload []byte) (int, error)
+
+// Registry knows the TaskFunc behind every task type
+type Registry struct {
+	mu 		sync.RWMutex
+	tasks 	map[string]TaskFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tasks: make(map[string]TaskFunc)}
+}
+
+// Register makes fn the function for tasks of type name, replacing the
+// previous one if any
+func (r *Registry) Register(name string, fn TaskFunc) {
+	r.mu.Lock()
+	r.tasks[name] = fn
+	r.mu.Unlock()
+}
+
+// Job returns the Job running a task of type name with payload
+func (r *Registry) Job(name string, payload []byte) (Job, error) {
+	r.mu.RLock()
+	fn, ok := r.tasks[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Workerpool: unknown task type %q", name)
+	}
+	return func(ctx context.Context) (int, error) {
+		return fn(ctx, payload)
+	}, nil
+}
+
+// Request returns a Request running a task of type name with payload, which
+// a process worker can run in its child process
+func (r *Registry) Request(name string, payload []byte, result chan Result, opts ...RequestOption) (Request, error) {
+	job, err := r.Job(name, payload)
+	if err != nil {
+		return Request{}, err
+	}
+	opts = append([]RequestOption{WithType(name), WithPayload(payload)}, opts...)
+	return NewRequest(job, result, opts...), nil
+}
+
+// Types returns the registered task types, sorted
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.tasks))
+	for name := range r.tasks {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
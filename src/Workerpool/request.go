@@ -1,35 +1,67 @@
 package Workerpool 
 
 import (
+	"context"
 	"math/rand"
 	"time"
 )
 
+// Job is the work a Request asks for, it should give up once ctx is done
+type Job func(ctx context.Context) (int, error)
+
+// Result is what comes back for every Request
+type Result struct {
+	Value 		int
+	Err 		error
+	Attempts 	int		// how many times the job ran, more than 1 if it was retried
+	ID 			uint64	// the job the result is for, set by a Stream
+	Partial 	bool	// more results are coming for the job, see Emit
+}
+
 type Request struct {
-	job 		func() int // the function to perform
-	result 		chan int   // the channel to return the result
+	job 		Job 			// the function to perform
+	result 		chan Result 	// the channel to return the result
+	priority 	int 			// higher priorities run first, see WithPriority
+	ctx 		context.Context // passed to the job, see WithContext
+	retry 		*RetryPolicy 	// what to do when the job fails, see WithRetry
+	tenant 		string 			// who the request is for, see WithTenant
+	key 		string 			// requests with the same key run in order, see WithKey
+	typ 		string 			// the kind of job, see WithType
+	payload 	[]byte 			// what a process worker runs the task of typ with, see WithPayload
+	remote 		bool 			// a process worker can run it, see WithPayload
+	timeout 	time.Duration 	// how long the job gets, see WithTimeout
+}
+
+// NewRequest creates a Request which runs job and sends the outcome on result,
+// the outcome is dropped when result is nil
+func NewRequest(job Job, result chan Result, opts ...RequestOption) Request {
+	r := Request{job: job, result: result, ctx: context.Background()}
+	for _, o := range opts {
+		o.apply(&r)
+	}
+	return r
 }
 
 
 // Todo ..
-func job() int {
+func job(ctx context.Context) (int, error) {
 	time.Sleep((time.Duration(rand.Intn(4)) * time.Second) + time.Second)
-	return 1
+	return 1, nil
 }
 
 func Requester(requests chan Request) {
-	result := make(chan int)
+	stream := NewStream(requests, false)
+	go func() {
+		// results come back tagged with the ID of their job
+		for range stream.Results() {
+		}
+	}()
 	for {
 
 		// sleep for a while
 		time.Sleep((time.Duration(rand.Intn(4)) * time.Second) + time.Second)
 
-		select {
 		// request sent
-		case requests  <- Request{job, result}:
-
-		// result came back	
-		case <-result:
-		}
+		stream.Submit(job)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,60 @@
+package Workerpool
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy says what the pool does with a job which failed. Every retry goes
+// back through the Balancer, so it may run on another, less loaded, worker
+type RetryPolicy struct {
+	MaxAttempts 	int					// including the first one, 0 or 1 means no retries
+	Backoff 		time.Duration		// how long to wait before the first retry
+	MaxBackoff 		time.Duration		// the longest wait between attempts, no limit when 0
+	Multiplier 		float64				// how much the wait grows every attempt, defaults to 2
+	Jitter 			float64				// fraction of the wait which is random, between 0 and 1
+	Retryable 		func(error) bool	// which errors are worth retrying, all of them when nil
+}
+
+// retry tells whether a job which failed with err on the given attempt
+// should run again
+func (p *RetryPolicy) retry(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// backoff returns how long to wait after the given attempt failed
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	m := p.Multiplier
+	if m <= 0 {
+		m = 2
+	}
+	if p.Backoff <= 0 {
+		return 0
+	}
+	d := float64(p.Backoff) * math.Pow(m, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if d >= math.MaxInt64 {
+		// it grew past what a Duration holds
+		return math.MaxInt64
+	}
+	if p.Jitter > 0 {
+		d -= d * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// retry puts t back through the Balancer once its backoff is over
+func (b *Balancer) retry(t *task) {
+	b.backoff++
+	wait := b.clock().After(t.retry.backoff(t.attempts))
+	go func() {
+		<-wait
+		b.retries <- t
+	}()
+}
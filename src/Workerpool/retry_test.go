@@ -0,0 +1,98 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(2); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("got %v with jitter, want between 10ms and 20ms", d)
+		}
+	}
+
+	// without a MaxBackoff it keeps growing, up to the longest Duration
+	p = &RetryPolicy{Backoff: time.Second}
+	for attempt, last := 30, time.Duration(0); attempt < 2000; attempt += 10 {
+		d := p.backoff(attempt)
+		if d < last {
+			t.Fatalf("attempt %d: got %v after %v", attempt, d, last)
+		}
+		last = d
+	}
+	if d := p.backoff(2000); d != math.MaxInt64 {
+		t.Errorf("got %v, want the longest Duration", d)
+	}
+	if d := (&RetryPolicy{}).backoff(2000); d != 0 {
+		t.Errorf("got %v without a Backoff, want 0", d)
+	}
+}
+
+var (
+	errTemporary = errors.New("temporary")
+	errPermanent = errors.New("permanent")
+)
+
+// failing returns a job which fails with err the first n times it runs
+func failing(n int32, err error) Job {
+	var calls int32
+	return func(context.Context) (int, error) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			return 0, err
+		}
+		return 42, nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{})
+
+	policy := RetryPolicy{
+		MaxAttempts: 	3,
+		Backoff: 		time.Millisecond,
+		Jitter: 		0.5,
+		Retryable: 		func(err error) bool { return err != errPermanent },
+	}
+	result := make(chan Result)
+
+	requests <- NewRequest(failing(2, errTemporary), result, WithRetry(policy))
+	if r := <-result; r.Err != nil || r.Value != 42 || r.Attempts != 3 {
+		t.Errorf("got %+v, want 42 after 3 attempts", r)
+	}
+
+	requests <- NewRequest(failing(5, errTemporary), result, WithRetry(policy))
+	if r := <-result; r.Err != errTemporary || r.Attempts != 3 {
+		t.Errorf("got %+v, want to give up after 3 attempts", r)
+	}
+
+	requests <- NewRequest(failing(1, errPermanent), result, WithRetry(policy))
+	if r := <-result; r.Err != errPermanent || r.Attempts != 1 {
+		t.Errorf("got %+v, want a permanent error not to be retried", r)
+	}
+
+	requests <- NewRequest(failing(1, errTemporary), result)
+	if r := <-result; r.Err != errTemporary || r.Attempts != 1 {
+		t.Errorf("got %+v, want no retries without a policy", r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	requests <- NewRequest(failing(0, nil), result, WithContext(ctx), WithRetry(policy))
+	if r := <-result; r.Err != context.Canceled || r.Attempts != 0 {
+		t.Errorf("got %+v, want the canceled request not to run", r)
+	}
+}
@@ -0,0 +1,69 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Scaler grows and shrinks the Pool of a running Balancer. A worker is added
+// whenever the average load of the Pool goes above Threshold, and workers which
+// have been idle for longer than IdleTimeout are retired. The Pool is always
+// kept between Min and Max workers
+type Scaler struct {
+	Min 			int
+	Max 			int
+	Threshold 		float64			// average pending above which a worker is added
+	IdleTimeout 	time.Duration	// how long a worker can sit idle before it's retired
+	Interval 		time.Duration	// how often the Pool is checked, defaults to a second
+}
+
+const defaultInterval = time.Second
+
+func (s *Scaler) interval() time.Duration {
+	if s.Interval <= 0 {
+		return defaultInterval
+	}
+	return s.Interval
+}
+
+// bounds returns Min and Max, making sure the Pool never goes empty
+func (s *Scaler) bounds() (min, max int) {
+	min, max = s.Min, s.Max
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// scale adds or retires workers depending on the current load, it runs in
+// the Balance loop so it's the only one touching the heap
+func (b *Balancer) scale(now time.Time) {
+	min, max := b.Scaler.bounds()
+
+	if b.Pool.Len() < min || (b.Pool.Len() < max && b.load() > b.Scaler.Threshold) {
+		w := b.spawn()
+		heap.Push(b.Pool, w)
+		b.report(w)
+		b.deal()
+		return
+	}
+
+	var idle []*Worker
+	for _, w := range *b.Pool {
+		if w.pending == 0 && now.Sub(w.idle) >= b.Scaler.IdleTimeout {
+			idle = append(idle, w)
+		}
+	}
+	for _, w := range idle {
+		if b.Pool.Len() <= min {
+			break
+		}
+		// nothing is queued or running on an idle worker so it can go straight away
+		heap.Remove(b.Pool, w.index)
+		close(w.quit)
+		b.metrics().WorkerRemoved(w.id)
+	}
+}
@@ -0,0 +1,54 @@
+package Workerpool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScaleBounds(t *testing.T) {
+	done := make(chan *Worker)
+	b := &Balancer{
+		Pool: New(0, done),
+		Done: done,
+		Scaler: &Scaler{Min: 2, Max: 3, Threshold: 2, IdleTimeout: time.Minute},
+	}
+
+	// an empty pool should be brought up to Min
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		b.scale(now)
+	}
+	if b.Pool.Len() != 2 {
+		t.Fatalf("got %d workers, want 2", b.Pool.Len())
+	}
+
+	// load above the threshold should add workers but never more than Max
+	for _, w := range *b.Pool {
+		w.pending = 10
+	}
+	for i := 0; i < 5; i++ {
+		b.scale(now)
+	}
+	if b.Pool.Len() != 3 {
+		t.Fatalf("got %d workers, want 3", b.Pool.Len())
+	}
+
+	// idle workers are retired once the timeout passed, down to Min
+	for _, w := range *b.Pool {
+		w.pending = 0
+		w.idle = now
+	}
+	b.scale(now.Add(time.Second))
+	if b.Pool.Len() != 3 {
+		t.Fatalf("got %d workers, want 3 before the idle timeout", b.Pool.Len())
+	}
+	b.scale(now.Add(2 * time.Minute))
+	if b.Pool.Len() != 2 {
+		t.Fatalf("got %d workers, want 2", b.Pool.Len())
+	}
+	for i, w := range *b.Pool {
+		if w.index != i {
+			t.Errorf("worker at %d has index %d", i, w.index)
+		}
+	}
+}
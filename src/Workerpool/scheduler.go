@@ -0,0 +1,211 @@
+package Workerpool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Overlap says what a Scheduler does when a job is due while its previous
+// run hasn't finished yet
+type Overlap int
+
+const (
+	// OverlapSkip drops the run which is due
+	OverlapSkip Overlap = iota
+	// OverlapQueue holds the run back until the previous one is done, so
+	// runs never overlap but none are lost
+	OverlapQueue
+	// OverlapAllow submits the run anyway
+	OverlapAllow
+)
+
+type scheduled struct {
+	id 			int
+	schedule 	Schedule
+	overlap 	Overlap
+	job 		Job
+	opts 		[]RequestOption
+	next 		time.Time	// zero once the schedule is over
+	running 	int
+	queued 		int
+}
+
+type scheduledResult struct {
+	entry 	*scheduled
+	result 	Result
+}
+
+// Scheduler submits jobs to a Balancer after a delay, at fixed intervals or as a
+// cron expression says. Jobs are added and removed at any time, and only start
+// being submitted once Run is called
+type Scheduler struct {
+	// OnResult, if set, is called with the result of every run. It's called
+	// from Run so it shouldn't block
+	OnResult 	func(id int, r Result)
+
+	requests 	chan<- Request
+	clock 		Clock
+	mu 			sync.Mutex
+	entries 	map[int]*scheduled
+	lastID 		int
+	changed 	chan struct{}
+	finished 	chan scheduledResult
+}
+
+// NewScheduler creates a Scheduler submitting to requests, clock can be
+// nil to go by the real time
+func NewScheduler(requests chan<- Request, clock Clock) *Scheduler {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Scheduler{
+		requests: 	requests,
+		clock: 		clock,
+		entries: 	make(map[int]*scheduled),
+		changed: 	make(chan struct{}, 1),
+		finished: 	make(chan scheduledResult),
+	}
+}
+
+// Add schedules job and returns its id, opts apply to every Request submitted.
+// A job At a time which already passed runs straight away
+func (s *Scheduler) Add(schedule Schedule, overlap Overlap, job Job, opts ...RequestOption) int {
+	return s.add(schedule, overlap, job, opts, s.clock.Now())
+}
+
+func (s *Scheduler) add(schedule Schedule, overlap Overlap, job Job, opts []RequestOption, now time.Time) int {
+	next := schedule.Next(now)
+	if _, once := schedule.(at); once && next.IsZero() {
+		next = now
+	}
+	s.mu.Lock()
+	s.lastID++
+	e := &scheduled{
+		id: 		s.lastID,
+		schedule: 	schedule,
+		overlap: 	overlap,
+		job: 		job,
+		opts: 		opts,
+		next: 		next,
+	}
+	s.entries[e.id] = e
+	s.mu.Unlock()
+	s.notify()
+	return e.id
+}
+
+// Delay runs job once, d from now
+func (s *Scheduler) Delay(d time.Duration, job Job, opts ...RequestOption) int {
+	now := s.clock.Now()
+	return s.add(At(now.Add(d)), OverlapAllow, job, opts, now)
+}
+
+// Remove stops job id from being submitted, runs already submitted carry on
+func (s *Scheduler) Remove(id int) {
+	s.mu.Lock()
+	delete(s.entries, id)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// notify wakes up Run so it looks at the entries again
+func (s *Scheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run submits the jobs as they are due until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		var wake <-chan time.Time
+		if next, ok := s.fire(ctx); ok {
+			wake = s.clock.After(next.Sub(s.clock.Now()))
+		}
+
+		select {
+		case <-wake:
+		case <-s.changed:
+		case f := <-s.finished:
+			s.done(ctx, f)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fire submits every job which is due and returns when the next one is
+func (s *Scheduler) fire(ctx context.Context) (next time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if !e.next.After(now) {
+			switch {
+			case e.running == 0 || e.overlap == OverlapAllow:
+				s.submit(ctx, e)
+			case e.overlap == OverlapQueue:
+				e.queued++
+			}
+			// runs missed while nobody was looking only count once
+			for !e.next.IsZero() && !e.next.After(now) {
+				e.next = e.schedule.Next(e.next)
+			}
+			if e.next.IsZero() && e.running == 0 && e.queued == 0 {
+				delete(s.entries, e.id)
+				continue
+			}
+		}
+		if !e.next.IsZero() && (!ok || e.next.Before(next)) {
+			next, ok = e.next, true
+		}
+	}
+	return next, ok
+}
+
+// submit sends a run of e to the Balancer and waits for its result, both from
+// their own goroutine so a busy Balancer doesn't hold up the Scheduler
+func (s *Scheduler) submit(ctx context.Context, e *scheduled) {
+	e.running++
+	result := make(chan Result, 1)
+	r := NewRequest(e.job, result, append(append([]RequestOption(nil), e.opts...), WithContext(ctx))...)
+	go func() {
+		select {
+		case s.requests <- r:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case res := <-result:
+			select {
+			case s.finished <- scheduledResult{e, res}:
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// done takes note of a finished run, starting the next one if it was queued
+func (s *Scheduler) done(ctx context.Context, f scheduledResult) {
+	if s.OnResult != nil {
+		s.OnResult(f.entry.id, f.result)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := f.entry
+	e.running--
+	if e.queued > 0 {
+		e.queued--
+		s.submit(ctx, e)
+	} else if e.running == 0 && e.next.IsZero() {
+		delete(s.entries, e.id)
+	}
+}
@@ -0,0 +1,181 @@
+package Workerpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCron(t *testing.T) {
+	tests := []struct {
+		expr 	string
+		from 	string
+		want 	string
+	}{
+		{"* * * * *", "2020-01-01 10:00:30", "2020-01-01 10:01"},
+		{"*/15 9-17 * * 1-5", "2020-01-03 17:50:00", "2020-01-06 09:00"}, // friday evening
+		{"0 0 1 1 *", "2020-06-15 12:00:00", "2021-01-01 00:00"},
+		{"30 4 1,15 * 5", "2020-01-02 00:00:00", "2020-01-03 04:30"}, // either day matches
+		{"0 12 29 2 *", "2021-01-01 00:00:00", "2024-02-29 12:00"},
+		{"5/20 * * * 7", "2020-01-04 23:59:00", "2020-01-05 00:05"}, // 7 is sunday too
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		from, _ := time.Parse("2006-01-02 15:04:05", tt.from)
+		want, _ := time.Parse("2006-01-02 15:04", tt.want)
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q after %s: got %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q parsed fine, want an error", expr)
+		}
+	}
+}
+
+// receive returns the next request submitted, failing if there's none
+func receive(t *testing.T, requests <-chan Request) Request {
+	t.Helper()
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was submitted")
+	}
+	return Request{}
+}
+
+// nothing makes sure nothing gets submitted for a little while
+func nothing(t *testing.T, requests <-chan Request) {
+	t.Helper()
+	select {
+	case <-requests:
+		t.Fatal("got a request, want nothing")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// finish plays the part of the worker for r
+func finish(r Request) {
+	v, err := r.job(r.ctx)
+	r.result <- Result{Value: v, Err: err, Attempts: 1}
+}
+
+func startScheduler(t *testing.T) (*Scheduler, *fakeClock, chan Request) {
+	clock := newFakeClock()
+	requests := make(chan Request)
+	s := NewScheduler(requests, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+	return s, clock, requests
+}
+
+func TestSchedulerDelay(t *testing.T) {
+	s, clock, requests := startScheduler(t)
+	s.Delay(5*time.Second, ok)
+
+	clock.Advance(4 * time.Second)
+	nothing(t, requests)
+	clock.Advance(time.Second)
+	finish(receive(t, requests))
+	clock.Advance(time.Hour)
+	nothing(t, requests)
+}
+
+func TestSchedulerDue(t *testing.T) {
+	s, clock, requests := startScheduler(t)
+
+	// due already, they run without the clock moving
+	s.Delay(0, ok)
+	finish(receive(t, requests))
+	s.Add(At(clock.Now().Add(-time.Hour)), OverlapAllow, ok)
+	finish(receive(t, requests))
+	nothing(t, requests)
+
+	// and are forgotten once they ran
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.entries)
+		s.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d entries left", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		s, clock, requests := startScheduler(t)
+		s.Add(Every(time.Minute), OverlapSkip, ok)
+
+		clock.Advance(time.Minute)
+		first := receive(t, requests)
+		clock.Advance(time.Minute)
+		nothing(t, requests)
+		finish(first)
+		nothing(t, requests)
+		clock.Advance(time.Minute)
+		finish(receive(t, requests))
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		s, clock, requests := startScheduler(t)
+		s.Add(Every(time.Minute), OverlapQueue, ok)
+
+		clock.Advance(time.Minute)
+		first := receive(t, requests)
+		clock.Advance(time.Minute)
+		nothing(t, requests)
+		clock.Advance(time.Minute)
+		nothing(t, requests)
+		// the two runs missed go one after the other
+		finish(first)
+		second := receive(t, requests)
+		nothing(t, requests)
+		finish(second)
+		finish(receive(t, requests))
+		nothing(t, requests)
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		s, clock, requests := startScheduler(t)
+		s.Add(Every(time.Minute), OverlapAllow, ok)
+
+		clock.Advance(time.Minute)
+		first := receive(t, requests)
+		clock.Advance(time.Minute)
+		second := receive(t, requests)
+		finish(first)
+		finish(second)
+	})
+}
+
+func TestSchedulerRemove(t *testing.T) {
+	s, clock, requests := startScheduler(t)
+	results := make(chan Result, 1)
+	s.OnResult = func(id int, r Result) { results <- r }
+	cron, _ := ParseCron("0 * * * *")
+	id := s.Add(cron, OverlapSkip, ok)
+
+	clock.Advance(time.Hour)
+	finish(receive(t, requests))
+	if r := <-results; r.Value != 1 {
+		t.Errorf("got %+v, want 1", r)
+	}
+
+	s.Remove(id)
+	clock.Advance(time.Hour)
+	nothing(t, requests)
+}
@@ -0,0 +1,221 @@
+package Workerpool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskSpec is the body of a POST to /tasks
+type TaskSpec struct {
+	Type 		string	`json:"type"`
+	Payload 	string	`json:"payload,omitempty"`
+	Priority 	int		`json:"priority,omitempty"`
+	Tenant 		string	`json:"tenant,omitempty"`
+	Key 		string	`json:"key,omitempty"`
+}
+
+// States of a task submitted to a Server
+const (
+	StateQueued 	= "queued"
+	StateRunning 	= "running"
+	StateDone 		= "done"
+	StateCanceled 	= "canceled"
+)
+
+// TaskStatus is what a Server says about a task
+type TaskStatus struct {
+	ID 			uint64	`json:"id"`
+	Type 		string	`json:"type"`
+	State 		string	`json:"state"`
+	Value 		int		`json:"value"`
+	Error 		string	`json:"error,omitempty"`
+	Attempts 	int		`json:"attempts,omitempty"`
+}
+
+type serverTask struct {
+	status 	TaskStatus
+	cancel 	func()
+}
+
+// Server exposes a pool over HTTP and JSON so it can be used from anything able
+// to make a request:
+// 	POST   /tasks       submits a TaskSpec, returns its TaskStatus
+// 	GET    /tasks/{id}  returns the TaskStatus
+// 	DELETE /tasks/{id}  cancels the task, or forgets it if it's finished
+// 	GET    /types       lists the task types which can be submitted
+// Tasks are run by the functions in the Registry, with the payload as is.
+// Finished tasks are kept until they're deleted, or for Retain
+type Server struct {
+	// Retain is how long a finished task can be looked up before it's
+	// forgotten, forever when 0
+	Retain 		time.Duration
+
+	registry 	*Registry
+	requests 	chan<- Request
+
+	mu 			sync.Mutex
+	lastID 		uint64
+	tasks 		map[uint64]*serverTask
+}
+
+func NewServer(registry *Registry, requests chan<- Request) *Server {
+	return &Server{
+		registry: 	registry,
+		requests: 	requests,
+		tasks: 		make(map[uint64]*serverTask),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/types" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.registry.Types())
+	case r.URL.Path == "/tasks" && r.Method == "POST":
+		s.submit(w, r)
+	case strings.HasPrefix(r.URL.Path, "/tasks/"):
+		id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/tasks/"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "no such task")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			s.status(w, id)
+		case "DELETE":
+			s.cancel(w, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var spec TaskSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeError(w, http.StatusBadRequest, "bad task: "+err.Error())
+		return
+	}
+	job, err := s.registry.Job(spec.Type, []byte(spec.Payload))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.lastID++
+	t := &serverTask{
+		status: TaskStatus{ID: s.lastID, Type: spec.Type, State: StateQueued},
+		cancel: cancel,
+	}
+	s.tasks[t.status.ID] = t
+	status := t.status
+	s.mu.Unlock()
+
+	running := func(ctx context.Context) (int, error) {
+		s.mu.Lock()
+		queued := t.status.State == StateQueued
+		if queued {
+			t.status.State = StateRunning
+		}
+		s.mu.Unlock()
+		if !queued {
+			// canceled just as it was about to start
+			return 0, context.Canceled
+		}
+		return job(ctx)
+	}
+	result := make(chan Result, 1)
+	req := NewRequest(running, result, WithContext(ctx), WithPriority(spec.Priority), WithTenant(spec.Tenant), WithKey(spec.Key), WithType(spec.Type))
+	select {
+	case s.requests <- req:
+	case <-r.Context().Done():
+		cancel()
+		s.mu.Lock()
+		delete(s.tasks, status.ID)
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "pool is busy")
+		return
+	}
+
+	go func() {
+		res := <-result
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if t.status.State != StateCanceled {
+			t.status.State = StateDone
+		}
+		t.status.Value = res.Value
+		t.status.Attempts = res.Attempts
+		if res.Err != nil {
+			t.status.Error = res.Err.Error()
+		}
+		cancel()
+		if s.Retain > 0 {
+			time.AfterFunc(s.Retain, func() {
+				s.mu.Lock()
+				if s.tasks[status.ID] == t {
+					delete(s.tasks, status.ID)
+				}
+				s.mu.Unlock()
+			})
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *Server) status(w http.ResponseWriter, id uint64) {
+	s.mu.Lock()
+	t, ok := s.tasks[id]
+	var status TaskStatus
+	if ok {
+		status = t.status
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such task")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// cancel cancels a task which isn't finished, the task still shows up as canceled
+// until it's deleted again. A finished task is forgotten
+func (s *Server) cancel(w http.ResponseWriter, id uint64) {
+	s.mu.Lock()
+	t, ok := s.tasks[id]
+	var status TaskStatus
+	if ok {
+		switch t.status.State {
+		case StateQueued, StateRunning:
+			t.status.State = StateCanceled
+			t.cancel()
+		default:
+			delete(s.tasks, id)
+		}
+		status = t.status
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such task")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
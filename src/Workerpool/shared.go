@@ -0,0 +1,117 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// QueueMode says where the requests wait for a worker
+type QueueMode int
+
+const (
+	// PerWorker queues every request on a worker as soon as it comes in, up
+	// to Depth per worker. A request is stuck behind whatever its worker
+	// queued before it, even once another worker is free
+	PerWorker QueueMode = iota
+	// Shared keeps the requests in a single queue, a worker takes the next
+	// one once it's done with its own. Nobody waits behind a slow job while
+	// a worker is free, but a worker sits idle while the Balancer hands it
+	// its next request
+	Shared
+	// Hybrid queues up to Depth requests on each worker, enough to keep it
+	// busy, and the others in a shared queue until a worker has room
+	Hybrid
+)
+
+func (m QueueMode) String() string {
+	switch m {
+	case PerWorker:
+		return "per-worker"
+	case Shared:
+		return "shared"
+	case Hybrid:
+		return "hybrid"
+	}
+	return "unknown"
+}
+
+// depth returns how many requests can be queued or running on a worker
+func (b *Balancer) depth() int {
+	switch {
+	case b.Mode == Shared:
+		return 1
+	case b.Depth > 0:
+		return b.Depth
+	case b.Mode == Hybrid:
+		return 2
+	}
+	return int(defaultSize)
+}
+
+// enqueue puts t in the queue of the worker the Strategy picks, or in the
+// shared queue when that worker has no room for it
+func (b *Balancer) enqueue(t *task) {
+	w := b.strategy().Select(b.Pool)
+	if b.Mode == PerWorker {
+		b.assign(w, t)
+		return
+	}
+	if w.pending >= b.depth() {
+		// the least loaded worker, the Strategy may have its reasons not
+		// to pick it
+		w = (*b.Pool)[0]
+	}
+	if w.pending >= b.depth() {
+		heap.Push(&b.shared, t)
+		return
+	}
+	b.assign(w, t)
+}
+
+// pull hands the requests waiting in the shared queue to the workers with
+// room for them
+func (b *Balancer) pull() {
+	for b.shared.Len() > 0 && b.Pool.Len() > 0 && (*b.Pool)[0].pending < b.depth() {
+		b.assign((*b.Pool)[0], heap.Pop(&b.shared).(*task))
+	}
+}
+
+// load is the average number of requests per worker, the shared queue
+// included
+func (b *Balancer) load() float64 {
+	return b.Pool.stats() + float64(b.shared.Len())/float64(b.Pool.Len())
+}
+
+// Pull runs the requests on workers goroutines which take them straight off
+// requests as they're free, without a Balancer. Requests keep their context,
+// timeout and retries but not their priority, key, tenant or type, and a
+// failed job is retried by the worker it ran on. It returns once requests is
+// closed and the workers are done
+func Pull(requests <-chan Request, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := makeWorker()
+			for r := range requests {
+				if r.job == nil {
+					if r.result != nil {
+						r.result <- Result{Err: ErrNoJob}
+					}
+					continue
+				}
+				if r.ctx == nil {
+					r.ctx = context.Background()
+				}
+				t := &task{Request: r}
+				for t.run(w); t.again; t.run(w) {
+					time.Sleep(t.retry.backoff(t.attempts))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
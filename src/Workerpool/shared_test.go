@@ -0,0 +1,241 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueMode(t *testing.T) {
+	for _, c := range []struct {
+		mode 		QueueMode
+		depth 		int
+		queued 		int		// on each worker
+		waiting 	int
+	}{
+		{PerWorker, 2, 2, 0},
+		{Shared, 0, 1, 2},
+		{Hybrid, 0, 2, 2},
+		{Hybrid, 3, 3, 0},
+	} {
+		t.Run(c.mode.String(), func(t *testing.T) {
+			b := &Balancer{Mode: c.mode, Depth: c.depth}
+			requests := startBalancer(t, 2, b)
+			b.Pause()
+			result := make(chan Result, 6)
+			// per worker queues stop taking requests once they're full
+			go func() {
+				for i := 0; i < 6; i++ {
+					requests <- NewRequest(ok, result)
+				}
+			}()
+			// the rest wait in requests, which the Balancer stops reading
+			s := b.Inspect()
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); s = b.Inspect() {
+				n := len(s.Waiting)
+				for _, w := range s.Workers {
+					n += len(w.Queued)
+				}
+				if n == 2*c.queued+c.waiting {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			for _, w := range s.Workers {
+				if len(w.Queued) != c.queued {
+					t.Errorf("got %d queued on worker %d, want %d", len(w.Queued), w.ID, c.queued)
+				}
+			}
+			if len(s.Waiting) != c.waiting {
+				t.Errorf("got %d waiting, want %d", len(s.Waiting), c.waiting)
+			}
+
+			b.Resume()
+			for i := 0; i < 6; i++ {
+				if r := <-result; r.Err != nil {
+					t.Fatal(r.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestSharedQueue(t *testing.T) {
+	b := &Balancer{Mode: Shared}
+	requests := startBalancer(t, 2, b)
+
+	// a slow job holds up nobody while the other worker is free
+	gate := make(chan struct{})
+	defer close(gate)
+	slow := make(chan Result, 1)
+	requests <- NewRequest(func(context.Context) (int, error) {
+		<-gate
+		return 0, nil
+	}, slow)
+	result := make(chan Result)
+	for i := 0; i < 10; i++ {
+		requests <- NewRequest(ok, result)
+		select {
+		case <-result:
+		case <-time.After(time.Second):
+			t.Fatal("request stuck behind the slow one")
+		}
+	}
+
+	// the highest priority goes first, the cancelled one never does
+	for idle := false; !idle; {
+		for _, w := range b.Inspect().Workers {
+			idle = idle || w.Running == nil
+		}
+	}
+	b.Pause()
+	var order []int
+	ran := make(chan int, 3)
+	for p := 1; p <= 3; p++ {
+		p := p
+		requests <- NewRequest(func(context.Context) (int, error) {
+			ran <- p
+			return p, nil
+		}, make(chan Result, 1), WithPriority(p))
+	}
+	waiting := b.Inspect().Waiting
+	if len(waiting) != 2 {
+		t.Fatalf("got %d waiting, want 2", len(waiting))
+	}
+	if !b.Cancel(waiting[0].ID) {
+		t.Fatal("couldn't cancel a request in the shared queue")
+	}
+	b.Resume()
+	for i := 0; i < 2; i++ {
+		order = append(order, <-ran)
+	}
+	if order[0] != 1 || order[1] != 3 {
+		t.Errorf("ran %v, want the one queued on the worker then priority 3", order)
+	}
+}
+
+func TestPull(t *testing.T) {
+	requests := make(chan Request)
+	stopped := make(chan struct{})
+	go func() {
+		Pull(requests, 4)
+		close(stopped)
+	}()
+
+	result := make(chan Result, 20)
+	for i := 0; i < 20; i++ {
+		i := i
+		requests <- NewRequest(func(context.Context) (int, error) { return i, nil }, result)
+	}
+	sum := 0
+	for i := 0; i < 20; i++ {
+		sum += (<-result).Value
+	}
+	if sum != 190 {
+		t.Errorf("got a sum of %d, want 190", sum)
+	}
+
+	var calls int32
+	requests <- NewRequest(func(context.Context) (int, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return 0, errors.New("flaky")
+		}
+		return 1, nil
+	}, result, WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+	if r := <-result; r.Err != nil || r.Attempts != 3 {
+		t.Errorf("got %+v, want success on the third attempt", r)
+	}
+	requests <- NewRequest(nil, result)
+	if r := <-result; r.Err != ErrNoJob {
+		t.Errorf("got %v, want ErrNoJob", r.Err)
+	}
+
+	close(requests)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Pull didn't return once requests was closed")
+	}
+}
+
+func TestQueueModeTail(t *testing.T) {
+	// with heavy tailed jobs a request queued behind a long one waits for it
+	// even though other workers are free, a shared queue doesn't have that
+	sim := func(mode QueueMode) Report {
+		return Simulation{
+			Workers: 	8,
+			Mode: 		mode,
+			Arrivals: 	Exponential(time.Millisecond),
+			Durations: 	Pareto(2*time.Millisecond, 1.5),
+			Requests: 	20000,
+			Seed: 		1,
+		}.Run()
+	}
+	perWorker, shared, hybrid := sim(PerWorker), sim(Shared), sim(Hybrid)
+	if shared.P99 >= perWorker.P99 || hybrid.P99 >= perWorker.P99 {
+		t.Errorf("got p99 %v per worker, %v shared and %v hybrid", perWorker.P99, shared.P99, hybrid.P99)
+	}
+}
+
+func BenchmarkQueueMode(b *testing.B) {
+	for _, c := range []struct {
+		name 	string
+		mode 	QueueMode
+		depth 	int
+	}{
+		{"per_worker", PerWorker, 0},
+		{"per_worker_depth_2", PerWorker, 2},
+		{"shared", Shared, 0},
+		{"hybrid", Hybrid, 0},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			done := make(chan *Worker)
+			benchmarkBalancer(b, &Balancer{Pool: New(4, done), Done: done, Mode: c.mode, Depth: c.depth}, heavyTailed)
+		})
+	}
+	b.Run("pull", func(b *testing.B) {
+		requests := make(chan Request)
+		go Pull(requests, 4)
+		defer close(requests)
+		benchmarkRequests(b, requests, heavyTailed)
+	})
+}
+
+
+func TestQueueModeKeyBacklog(t *testing.T) {
+	for _, c := range []struct {
+		mode 	QueueMode
+		depth 	int
+	}{
+		{Shared, 0},
+		{PerWorker, 1},
+	} {
+		t.Run(c.mode.String(), func(t *testing.T) {
+			requests := startBalancer(t, 2, &Balancer{Mode: c.mode, Depth: c.depth})
+			gate := make(chan struct{})
+			defer close(gate)
+			blocked := func(context.Context) (int, error) {
+				<-gate
+				return 1, nil
+			}
+			backlog := make(chan Result, 3)
+			requests <- NewRequest(blocked, backlog, WithKey("a"))
+			requests <- NewRequest(ok, backlog, WithKey("a"))
+			requests <- NewRequest(ok, backlog, WithKey("a"))
+
+			// the other worker is free for another key
+			result := make(chan Result, 1)
+			requests <- NewRequest(ok, result, WithKey("b"))
+			select {
+			case r := <-result:
+				if r.Err != nil {
+					t.Fatal(r.Err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("key b never got in while key a had a backlog")
+			}
+		})
+	}
+}
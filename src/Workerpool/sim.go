@@ -0,0 +1,249 @@
+package Workerpool
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Distribution draws a random duration, it's how a Simulation is told
+// how often requests come in and how long they take
+type Distribution func(r *rand.Rand) time.Duration
+
+// Constant always returns d
+func Constant(d time.Duration) Distribution {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// Uniform returns durations spread evenly between min and max
+func Uniform(min, max time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return min + time.Duration(r.Int63n(int64(max-min)+1))
+	}
+}
+
+// Exponential returns durations with the given mean, the gaps between
+// requests which come in independently of each other look like this
+func Exponential(mean time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+// Pareto returns durations of at least min with a heavy tail, the lower
+// alpha the more often a job takes many times longer than usual
+func Pareto(min time.Duration, alpha float64) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(float64(min) / math.Pow(1-r.Float64(), 1/alpha))
+	}
+}
+
+// Simulation runs a Balancer against a synthetic workload on a virtual clock.
+// Nothing sleeps and no goroutines are involved, so a run with the same Seed
+// always gives the same Report and takes milliseconds however long the
+// simulated jobs are
+type Simulation struct {
+	Workers 	int				// number of workers with capacity 1, when Capacities is nil
+	Capacities 	[]int			// a worker with capacity 2 runs its jobs twice as fast
+	Strategy 	Strategy		// defaults to LeastLoaded, see PowerOfTwo.Rand
+	Steal 		bool
+	Aging 		time.Duration
+	Mode 		QueueMode
+	Depth 		int
+	Arrivals 	Distribution	// time between two requests
+	Durations 	Distribution	// how long a job takes on a worker with capacity 1
+	Requests 	int				// how many requests to simulate
+	Seed 		int64
+}
+
+// Report is what came out of a Simulation. Latencies go from the moment
+// a request arrived to the moment its job finished
+type Report struct {
+	Requests 	int
+	Mean 		time.Duration
+	P50 		time.Duration
+	P90 		time.Duration
+	P99 		time.Duration
+	Max 		time.Duration
+	Makespan 	time.Duration	// from the first arrival to the last completion
+	Imbalance 	float64			// busy time of the busiest worker over the average, 1 is perfect
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("requests=%d mean=%v p50=%v p90=%v p99=%v max=%v makespan=%v imbalance=%.2f",
+		r.Requests, r.Mean, r.P50, r.P90, r.P99, r.Max, r.Makespan, r.Imbalance)
+}
+
+// simClock is the virtual clock of a Simulation, it only moves when
+// the next event is due
+type simClock struct {
+	now 	time.Time
+	timers 	[]simTimer
+}
+
+type simTimer struct {
+	at 	time.Time
+	c 	chan time.Time
+}
+
+func (c *simClock) Now() time.Time {
+	return c.now
+}
+
+func (c *simClock) After(d time.Duration) <-chan time.Time {
+	t := simTimer{c.now.Add(d), make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c
+	}
+	c.timers = append(c.timers, t)
+	return t.c
+}
+
+func (c *simClock) set(now time.Time) {
+	c.now = now
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- now
+	}
+	c.timers = timers
+}
+
+// completion is a job which is going to finish at a given time
+type completion struct {
+	at 		time.Time
+	worker 	*Worker
+	request int
+}
+
+type completions []completion
+
+func (c completions) Len() int { return len(c) }
+func (c completions) Less(i, j int) bool { return c[i].at.Before(c[j].at) }
+func (c completions) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c *completions) Push(x interface{}) { *c = append(*c, x.(completion)) }
+func (c *completions) Pop() interface{} {
+	old := *c
+	x := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return x
+}
+
+// Run simulates the workload and reports how the Balancer coped with it
+func (s Simulation) Run() Report {
+	r := rand.New(rand.NewSource(s.Seed))
+	capacities := s.Capacities
+	if capacities == nil {
+		capacities = make([]int, s.Workers)
+	}
+	clock := &simClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := &Balancer{
+		Pool: 		weighted(capacities, makeWorker),
+		Strategy: 	s.Strategy,
+		Steal: 		s.Steal,
+		Aging: 		s.Aging,
+		Mode: 		s.Mode,
+		Depth: 		s.Depth,
+		Clock: 		clock,
+	}
+	b.lazyInit()
+
+	// the workload is drawn up front so it doesn't depend on the Strategy,
+	// every job returns its index so the simulation can look it up when the
+	// job starts
+	arrived := make([]time.Time, s.Requests)
+	durations := make([]time.Duration, s.Requests)
+	at := clock.now
+	for i := range arrived {
+		at = at.Add(s.Arrivals(r))
+		arrived[i] = at
+		durations[i] = s.Durations(r)
+	}
+
+	var (
+		pending 	completions
+		busy 		= make(map[*Worker]time.Duration)
+		latencies 	= make([]time.Duration, 0, s.Requests)
+		next 		int
+	)
+	start := func() {
+		for _, w := range *b.Pool {
+			select {
+			case t := <-w.requests:
+				i, _ := t.call(w, t.ctx)
+				t.elapsed = durations[i] / time.Duration(w.capacity)
+				busy[w] += t.elapsed
+				heap.Push(&pending, completion{clock.now.Add(t.elapsed), w, i})
+			default:
+			}
+		}
+	}
+
+	for next < len(arrived) || pending.Len() > 0 {
+		// an arrival which finds every worker full waits for a completion,
+		// just like a request blocked on the Balance loop
+		arrive := next < len(arrived) && !b.full() &&
+			(pending.Len() == 0 || !pending[0].at.Before(arrived[next]))
+		if arrive {
+			if arrived[next].After(clock.now) {
+				clock.set(arrived[next])
+			}
+			i := next
+			next++
+			b.dispatch(NewRequest(func(context.Context) (int, error) { return i, nil }, nil))
+		} else {
+			c := heap.Pop(&pending).(completion)
+			clock.set(c.at)
+			latencies = append(latencies, c.at.Sub(arrived[c.request]))
+			b.complete(c.worker)
+		}
+		start()
+	}
+
+	if len(latencies) == 0 {
+		return Report{}
+	}
+	return Report{
+		Requests: 	len(latencies),
+		Makespan: 	clock.now.Sub(arrived[0]),
+		Imbalance: 	imbalance(busy, b.Pool),
+	}.latencies(latencies)
+}
+
+// latencies fills in the latency part of the report
+func (r Report) latencies(l []time.Duration) Report {
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	var total time.Duration
+	for _, d := range l {
+		total += d
+	}
+	r.Mean = total / time.Duration(len(l))
+	r.P50 = l[len(l)*50/100]
+	r.P90 = l[len(l)*90/100]
+	r.P99 = l[len(l)*99/100]
+	r.Max = l[len(l)-1]
+	return r
+}
+
+// imbalance compares the busiest worker of p to the average one
+func imbalance(busy map[*Worker]time.Duration, p *Pool) float64 {
+	var total, max time.Duration
+	for _, w := range *p {
+		total += busy[w]
+		if busy[w] > max {
+			max = busy[w]
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(max) * float64(p.Len()) / float64(total)
+}
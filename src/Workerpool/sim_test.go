@@ -0,0 +1,78 @@
+package Workerpool
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSimulationExact(t *testing.T) {
+	// one request every 10ms, each taking 15ms on one of two workers, nobody
+	// ever has to wait
+	r := Simulation{
+		Workers: 	2,
+		Arrivals: 	Constant(10 * time.Millisecond),
+		Durations: 	Constant(15 * time.Millisecond),
+		Requests: 	100,
+	}.Run()
+	if r.Requests != 100 || r.Max != 15*time.Millisecond || r.P50 != 15*time.Millisecond {
+		t.Errorf("got %v, want every request to take 15ms", r)
+	}
+	if r.Makespan != 99*10*time.Millisecond+15*time.Millisecond {
+		t.Errorf("got makespan %v", r.Makespan)
+	}
+
+	// a single worker falls behind by 5ms every request
+	r = Simulation{
+		Workers: 	1,
+		Arrivals: 	Constant(10 * time.Millisecond),
+		Durations: 	Constant(15 * time.Millisecond),
+		Requests: 	10,
+	}.Run()
+	if r.Max != 15*time.Millisecond+9*5*time.Millisecond {
+		t.Errorf("got max %v, want 60ms", r.Max)
+	}
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	sim := func() Report {
+		return Simulation{
+			Workers: 	8,
+			Strategy: 	PowerOfTwo{Rand: rand.New(rand.NewSource(1))},
+			Arrivals: 	Exponential(time.Millisecond),
+			Durations: 	Pareto(2*time.Millisecond, 1.5),
+			Requests: 	10000,
+			Seed: 		42,
+		}.Run()
+	}
+	if a, b := sim(), sim(); a != b {
+		t.Errorf("same seed gave\n%v\n%v", a, b)
+	}
+}
+
+func TestSimulationStrategies(t *testing.T) {
+	sim := func(s Strategy, steal bool) Report {
+		return Simulation{
+			Workers: 	8,
+			Strategy: 	s,
+			Steal: 		steal,
+			Arrivals: 	Exponential(time.Millisecond),
+			Durations: 	Pareto(2*time.Millisecond, 1.5),
+			Requests: 	20000,
+			Seed: 		7,
+		}.Run()
+	}
+	least := sim(LeastLoaded{}, false)
+	robin := sim(&RoundRobin{}, false)
+	stolen := sim(&RoundRobin{}, true)
+	t.Logf("least loaded: %v", least)
+	t.Logf("round robin:  %v", robin)
+	t.Logf("with steal:   %v", stolen)
+
+	if least.P99 > robin.P99 {
+		t.Errorf("least loaded p99 %v is worse than round robin %v", least.P99, robin.P99)
+	}
+	if stolen.P99 > robin.P99 {
+		t.Errorf("stealing made round robin p99 worse, %v against %v", stolen.P99, robin.P99)
+	}
+}
@@ -0,0 +1,56 @@
+package Workerpool
+
+import "container/heap"
+
+// steal moves a queued task from the busiest worker over to thief, keeping the
+// pending counts of both in line. It takes the tail of the victim's queue as
+// that is the task which would have had to wait the longest. Returns false when
+// there was nothing to steal
+func (b *Balancer) steal(thief *Worker) bool {
+	var victim *Worker
+	for _, w := range *b.Pool {
+		if w != thief && w.queue.Len() > 0 && (victim == nil || w.queue.Len() > victim.queue.Len()) {
+			victim = w
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	t := heap.Remove(&victim.queue, victim.queue.tail()).(*task)
+	victim.pending -= 1
+	heap.Fix(b.Pool, victim.index)
+	b.report(victim)
+
+	heap.Push(&thief.queue, t)
+	thief.pending += 1
+	heap.Fix(b.Pool, thief.index)
+	return true
+}
+
+// feedIdle gets the idle workers to steal, they would otherwise only
+// look for something to steal once they finished their own work
+func (b *Balancer) feedIdle() {
+	// stealing reorders the heap, so pick the workers out first
+	var idle []*Worker
+	for _, w := range *b.Pool {
+		if w.running == nil && w.queue.Len() == 0 {
+			idle = append(idle, w)
+		}
+	}
+	for _, w := range idle {
+		b.feed(w)
+	}
+}
+
+// tail returns the index of the lowest ranked task, which has to be one
+// of the leaves of the heap
+func (q queue) tail() int {
+	last := len(q) - 1
+	for i := len(q) / 2; i < len(q); i++ {
+		if q.Less(last, i) {
+			last = i
+		}
+	}
+	return last
+}
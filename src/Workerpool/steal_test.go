@@ -0,0 +1,96 @@
+package Workerpool
+
+import (
+	"context"
+	"container/heap"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSteal(t *testing.T) {
+	done := make(chan *Worker)
+	b := &Balancer{Pool: New(3, done), Done: done}
+	victim, other, thief := (*b.Pool)[0], (*b.Pool)[1], (*b.Pool)[2]
+
+	// pretend the victim is stuck on something with 3 requests queued
+	victim.running = &task{}
+	for r := int64(1); r <= 3; r++ {
+		heap.Push(&victim.queue, &task{rank: r})
+	}
+	victim.pending = 4
+	other.running = &task{}
+	heap.Push(&other.queue, &task{rank: 10})
+	other.pending = 2
+	heap.Init(b.Pool)
+
+	if !b.steal(thief) {
+		t.Fatal("nothing was stolen")
+	}
+	if thief.queue.Len() != 1 || thief.queue[0].rank != 1 {
+		t.Errorf("should have stolen the lowest ranked task of the busiest worker")
+	}
+	if victim.pending != 3 || thief.pending != 1 {
+		t.Errorf("got pending %d and %d, want 3 and 1", victim.pending, thief.pending)
+	}
+	for i, w := range *b.Pool {
+		if w.index != i {
+			t.Errorf("worker at %d has index %d", i, w.index)
+		}
+	}
+
+	victim.queue, other.queue = nil, nil
+	if b.steal(thief) {
+		t.Error("stole from empty queues")
+	}
+}
+
+func TestStealWhileBlocked(t *testing.T) {
+	requests := startBalancer(t, 2, &Balancer{Strategy: &RoundRobin{}, Steal: true})
+
+	// round robin puts every other request behind the stuck one, the
+	// other worker has to steal them for everything to finish
+	gate := make(chan struct{})
+	defer close(gate)
+	blocked := make(chan Result, 1)
+	requests <- NewRequest(func(context.Context) (int, error) { <-gate; return 0, nil }, blocked)
+
+	result := make(chan Result, 10)
+	for i := 0; i < 10; i++ {
+		requests <- NewRequest(func(context.Context) (int, error) { return 1, nil }, result)
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case <-result:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d requests finished", i)
+		}
+	}
+}
+
+// heavyTailed returns jobs with pareto distributed durations, most are short
+// but a few take hundreds of times longer
+func heavyTailed(r *rand.Rand) Job {
+	d := time.Duration(float64(50*time.Microsecond) / math.Pow(1-r.Float64(), 1/1.2))
+	if d > 50*time.Millisecond {
+		d = 50 * time.Millisecond
+	}
+	return func(context.Context) (int, error) {
+		time.Sleep(d)
+		return 1, nil
+	}
+}
+
+func BenchmarkSteal(b *testing.B) {
+	for _, steal := range []bool{false, true} {
+		name := "off"
+		if steal {
+			name = "on"
+		}
+		b.Run(name, func(b *testing.B) {
+			done := make(chan *Worker)
+			benchmarkBalancer(b, &Balancer{Pool: New(4, done), Done: done, Steal: steal}, heavyTailed)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package Workerpool
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Strategy picks the worker a request is dispatched to. Select is only called
+// from the Balance loop with a non empty Pool, so implementations don't need
+// to be safe for concurrent use
+type Strategy interface {
+	Select(p *Pool) *Worker
+}
+
+// LeastLoaded picks the worker with the fewest pending requests, which is
+// always at the top of the heap
+type LeastLoaded struct{}
+
+func (LeastLoaded) Select(p *Pool) *Worker {
+	return (*p)[0]
+}
+
+// RoundRobin hands requests to every worker in turn, regardless of their load
+type RoundRobin struct {
+	last int // id of the last worker picked
+}
+
+func (r *RoundRobin) Select(p *Pool) *Worker {
+	// the heap is reordered all the time so go by id, picking the
+	// next one after the last worker or wrapping around to the lowest
+	var next, first *Worker
+	for _, w := range *p {
+		if first == nil || w.id < first.id {
+			first = w
+		}
+		if w.id > r.last && (next == nil || w.id < next.id) {
+			next = w
+		}
+	}
+	if next == nil {
+		next = first
+	}
+	r.last = next.id
+	return next
+}
+
+// PowerOfTwo picks two workers at random and goes with the least loaded one,
+// which gets close to LeastLoaded without having to know about the whole Pool
+type PowerOfTwo struct {
+	Rand 	*rand.Rand	// where the picks come from, the global source when nil
+}
+
+func (s PowerOfTwo) Select(p *Pool) *Worker {
+	n := p.Len()
+	if n == 1 {
+		return (*p)[0]
+	}
+	intn := rand.Intn
+	if s.Rand != nil {
+		intn = s.Rand.Intn
+	}
+	i := intn(n)
+	j := intn(n - 1)
+	if j >= i {
+		j++
+	}
+	if (*p)[j].pending < (*p)[i].pending {
+		return (*p)[j]
+	}
+	return (*p)[i]
+}
+
+// Weighted picks the worker with the fewest pending requests relative to its
+// capacity, see NewWeighted
+type Weighted struct{}
+
+func (Weighted) Select(p *Pool) *Worker {
+	best := (*p)[0]
+	for _, w := range (*p)[1:] {
+		// pending/capacity < best.pending/best.capacity without the division
+		if w.pending*best.capacity < best.pending*w.capacity {
+			best = w
+		}
+	}
+	return best
+}
+
+// LeastExpected picks the worker expected to finish the request first, going by
+// the moving average of how long the jobs took on each worker so far. Workers
+// which haven't finished anything yet are assumed to be as fast as the Default
+type LeastExpected struct {
+	Default time.Duration
+}
+
+func (l LeastExpected) Select(p *Pool) *Worker {
+	var best *Worker
+	var bestEta time.Duration
+	for _, w := range *p {
+		avg := w.avg
+		if avg == 0 {
+			avg = l.Default
+		}
+		eta := time.Duration(w.pending+1) * avg
+		if best == nil || eta < bestEta || (eta == bestEta && w.pending < best.pending) {
+			best, bestEta = w, eta
+		}
+	}
+	return best
+}
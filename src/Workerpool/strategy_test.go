@@ -0,0 +1,151 @@
+package Workerpool
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func loaded(pending ...int) *Pool {
+	p := New(len(pending), make(chan *Worker))
+	for i, n := range pending {
+		(*p)[i].pending = n
+	}
+	return p
+}
+
+func TestRoundRobin(t *testing.T) {
+	p := loaded(5, 0, 3)
+	var r RoundRobin
+	seen := make(map[*Worker]int)
+	for i := 0; i < 9; i++ {
+		seen[r.Select(p)]++
+	}
+	for _, w := range *p {
+		if seen[w] != 3 {
+			t.Errorf("worker %d picked %d times, want 3", w.id, seen[w])
+		}
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	p := loaded(0, 7)
+	// with two workers both are always compared
+	for i := 0; i < 10; i++ {
+		if w := (PowerOfTwo{}).Select(p); w.pending != 0 {
+			t.Fatalf("picked worker with %d pending, want 0", w.pending)
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	p := NewWeighted([]int{1, 4}, make(chan *Worker))
+	(*p)[0].pending = 1
+	(*p)[1].pending = 3
+	if w := (Weighted{}).Select(p); w.capacity != 4 {
+		t.Errorf("picked worker with capacity %d, want 4", w.capacity)
+	}
+}
+
+func TestLeastExpected(t *testing.T) {
+	p := loaded(1, 3)
+	(*p)[0].avg = time.Second
+	(*p)[1].avg = time.Millisecond
+	if w := (LeastExpected{}).Select(p); w != (*p)[1] {
+		t.Errorf("picked worker with avg %v, want 1ms", w.avg)
+	}
+
+	// an unmeasured worker goes by the default
+	(*p)[1].avg = 0
+	if w := (LeastExpected{Default: time.Hour}).Select(p); w != (*p)[0] {
+		t.Errorf("picked the unmeasured worker")
+	}
+}
+
+// skewed returns a job which is usually quick but every now and then takes
+// a lot longer, like most real workloads
+func skewed(r *rand.Rand) Job {
+	d := 20 * time.Microsecond
+	if r.Intn(10) == 0 {
+		d = 2 * time.Millisecond
+	}
+	return func(context.Context) (int, error) {
+		time.Sleep(d)
+		return 1, nil
+	}
+}
+
+// benchmarkBalancer keeps a few clients submitting jobs to bal and waiting for
+// them, reporting the mean and the 99th percentile latency of a request
+func benchmarkBalancer(b *testing.B, bal *Balancer, job func(*rand.Rand) Job) {
+	requests := make(chan Request)
+	go bal.Balance(requests)
+	benchmarkRequests(b, requests, job)
+}
+
+// benchmarkRequests is benchmarkBalancer for whatever takes the requests
+func benchmarkRequests(b *testing.B, requests chan Request, job func(*rand.Rand) Job) {
+	const clients = 16
+
+	var (
+		mu 			sync.Mutex
+		n 			int
+		latencies 	[]time.Duration
+	)
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			result := make(chan Result)
+			for {
+				mu.Lock()
+				if n == b.N {
+					mu.Unlock()
+					return
+				}
+				n++
+				mu.Unlock()
+
+				start := time.Now()
+				requests <- NewRequest(job(r), result)
+				<-result
+				mu.Lock()
+				latencies = append(latencies, time.Since(start))
+				mu.Unlock()
+			}
+		}(int64(c))
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(total.Microseconds())/float64(len(latencies)), "µs/req")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs/req")
+}
+
+func benchmarkStrategy(b *testing.B, s Strategy) {
+	done := make(chan *Worker)
+	benchmarkBalancer(b, &Balancer{
+		Pool: NewWeighted([]int{1, 1, 2, 2}, done),
+		Done: done,
+		Strategy: s,
+	}, skewed)
+}
+
+func BenchmarkStrategy(b *testing.B) {
+	b.Run("least_loaded", func(b *testing.B) { benchmarkStrategy(b, LeastLoaded{}) })
+	b.Run("round_robin", func(b *testing.B) { benchmarkStrategy(b, &RoundRobin{}) })
+	b.Run("power_of_two", func(b *testing.B) { benchmarkStrategy(b, PowerOfTwo{}) })
+	b.Run("weighted", func(b *testing.B) { benchmarkStrategy(b, Weighted{}) })
+	b.Run("least_expected", func(b *testing.B) { benchmarkStrategy(b, LeastExpected{}) })
+}
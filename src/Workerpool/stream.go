@@ -0,0 +1,244 @@
+package Workerpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Progress is how far along a job said it was, see ReportProgress
+type Progress struct {
+	Done 	int
+	Total 	int
+}
+
+// Future is the result of a job submitted through a Stream, along with the
+// partial results and progress the job reported while it ran
+type Future struct {
+	ID 			uint64
+
+	mu 			sync.Mutex
+	partials 	[]int
+	progress 	Progress
+	updated 	chan struct{}	// closed and replaced every time something changes
+	result 		Result
+	done 		chan struct{}
+	stream 		*Stream
+}
+
+// Done is closed once the final result is in
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the final result
+func (f *Future) Result() Result {
+	<-f.done
+	return f.result
+}
+
+// Wait waits for the final result or for ctx to be done
+func (f *Future) Wait(ctx context.Context) (Result, error) {
+	select {
+	case <-f.done:
+		return f.result, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// Partials returns the partial results the job emitted so far, see Emit
+func (f *Future) Partials() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.partials...)
+}
+
+// Progress returns the latest progress the job reported
+func (f *Future) Progress() Progress {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.progress
+}
+
+// Updated returns a channel which is closed the next time the job emits
+// a partial result, reports progress or is done
+func (f *Future) Updated() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.updated
+}
+
+// update changes the future under its lock and wakes up whoever is
+// waiting on Updated
+func (f *Future) update(change func()) {
+	f.mu.Lock()
+	change()
+	close(f.updated)
+	f.updated = make(chan struct{})
+	f.mu.Unlock()
+}
+
+type futureKey struct{}
+
+// Emit sends a partial result from a job submitted through a Stream, it shows
+// up in the Future and on the Results of the Stream. It returns false when the
+// job wasn't submitted through a Stream
+func Emit(ctx context.Context, v int) bool {
+	f, ok := ctx.Value(futureKey{}).(*Future)
+	if !ok {
+		return false
+	}
+	f.update(func() {
+		f.partials = append(f.partials, v)
+	})
+	f.stream.deliver(Result{ID: f.ID, Value: v, Partial: true})
+	return true
+}
+
+// ReportProgress tells the Future of a job submitted through a Stream how far
+// along it is. It returns false when the job wasn't submitted through a Stream
+func ReportProgress(ctx context.Context, done, total int) bool {
+	f, ok := ctx.Value(futureKey{}).(*Future)
+	if !ok {
+		return false
+	}
+	f.update(func() {
+		f.progress = Progress{done, total}
+	})
+	return true
+}
+
+// Stream submits jobs and hands out a Future for each of them, so results can
+// be told apart however many jobs are out. All the results, partial ones
+// included, also come out of Results tagged with the ID of their job
+type Stream struct {
+	requests 	chan<- Request
+	ordered 	bool
+
+	mu 			sync.Mutex
+	wake 		*sync.Cond
+	lastID 		uint64
+	out 		int					// submitted jobs whose final result isn't in yet
+	closed 		bool
+	ready 		[]Result			// waiting to go out on results
+	held 		map[uint64][]Result	// results of later jobs, when ordered
+	final 		map[uint64]bool		// the jobs in held which are done
+	next 		uint64				// the job whose results go out next, when ordered
+	results 	chan Result
+}
+
+// NewStream returns a Stream sending its requests to requests. With ordered
+// the results come out of Results in the order the jobs were submitted,
+// otherwise they come out as soon as they're in
+func NewStream(requests chan<- Request, ordered bool) *Stream {
+	s := &Stream{
+		requests: 	requests,
+		ordered: 	ordered,
+		held: 		make(map[uint64][]Result),
+		final: 		make(map[uint64]bool),
+		next: 		1,
+		results: 	make(chan Result),
+	}
+	s.wake = sync.NewCond(&s.mu)
+	go s.pump()
+	return s
+}
+
+// Submit sends job off and returns its Future. The Stream must not be closed
+func (s *Stream) Submit(job Job, opts ...RequestOption) *Future {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		panic("Workerpool: Submit on a closed Stream")
+	}
+	s.lastID++
+	s.out++
+	f := &Future{
+		ID: 		s.lastID,
+		updated: 	make(chan struct{}),
+		done: 		make(chan struct{}),
+		stream: 	s,
+	}
+	s.mu.Unlock()
+
+	result := make(chan Result, 1)
+	r := NewRequest(func(ctx context.Context) (int, error) {
+		return job(context.WithValue(ctx, futureKey{}, f))
+	}, result, opts...)
+	s.requests <- r
+	go func() {
+		res := <-result
+		res.ID = f.ID
+		f.update(func() {
+			f.result = res
+		})
+		close(f.done)
+		s.deliver(res)
+	}()
+	return f
+}
+
+// Results returns every result of the jobs, partial ones included. It has to
+// be read, the Stream holds on to the results until they are, and it's closed
+// once the Stream is closed and the last result went out
+func (s *Stream) Results() <-chan Result {
+	return s.results
+}
+
+// Close says no more jobs are coming
+func (s *Stream) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.wake.Signal()
+	s.mu.Unlock()
+}
+
+// deliver queues r to go out on the results, in order if need be
+func (s *Stream) deliver(r Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !r.Partial {
+		s.out--
+	}
+	if !s.ordered {
+		s.ready = append(s.ready, r)
+		s.wake.Signal()
+		return
+	}
+
+	s.held[r.ID] = append(s.held[r.ID], r)
+	if !r.Partial {
+		s.final[r.ID] = true
+	}
+	for len(s.held[s.next]) > 0 {
+		s.ready = append(s.ready, s.held[s.next]...)
+		delete(s.held, s.next)
+		if !s.final[s.next] {
+			break
+		}
+		delete(s.final, s.next)
+		s.next++
+	}
+	s.wake.Signal()
+}
+
+// pump sends the results out as they're ready, so a job never waits on
+// whoever reads them
+func (s *Stream) pump() {
+	s.mu.Lock()
+	for {
+		for len(s.ready) == 0 && !(s.closed && s.out == 0) {
+			s.wake.Wait()
+		}
+		if len(s.ready) == 0 {
+			s.mu.Unlock()
+			close(s.results)
+			return
+		}
+		r := s.ready[0]
+		s.ready = s.ready[1:]
+		s.mu.Unlock()
+		s.results <- r
+		s.mu.Lock()
+	}
+}
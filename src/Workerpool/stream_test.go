@@ -0,0 +1,108 @@
+package Workerpool
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamFutures(t *testing.T) {
+	s := NewStream(startBalancer(t, 4, &Balancer{}), false)
+	futures := make(map[uint64]*Future)
+	for i := 0; i < 10; i++ {
+		v := i
+		f := s.Submit(func(context.Context) (int, error) {
+			time.Sleep(time.Duration(10-v) * time.Millisecond)
+			return v * v, nil
+		})
+		futures[f.ID] = f
+		defer func(f *Future, want int) {
+			if r := f.Result(); r.Value != want || r.ID != f.ID {
+				t.Errorf("future %d got %+v, want %d", f.ID, r, want)
+			}
+		}(f, v*v)
+	}
+	s.Close()
+
+	seen := make(map[uint64]bool)
+	for r := range s.Results() {
+		if futures[r.ID] == nil || seen[r.ID] {
+			t.Errorf("got a result for job %d", r.ID)
+		}
+		seen[r.ID] = true
+		if r.Value != futures[r.ID].Result().Value {
+			t.Errorf("stream and future disagree on job %d", r.ID)
+		}
+	}
+	if len(seen) != 10 {
+		t.Errorf("got %d results, want 10", len(seen))
+	}
+}
+
+func TestStreamOrdered(t *testing.T) {
+	s := NewStream(startBalancer(t, 4, &Balancer{}), true)
+	for i := 0; i < 10; i++ {
+		v := i
+		s.Submit(func(ctx context.Context) (int, error) {
+			// the later jobs finish first
+			time.Sleep(time.Duration(10-v) * time.Millisecond)
+			Emit(ctx, -v)
+			return v, nil
+		})
+	}
+	s.Close()
+
+	var got []int
+	for r := range s.Results() {
+		got = append(got, r.Value)
+	}
+	want := []int{0, 0, -1, 1, -2, 2, -3, 3, -4, 4, -5, 5, -6, 6, -7, 7, -8, 8, -9, 9}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStreamPartials(t *testing.T) {
+	s := NewStream(startBalancer(t, 1, &Balancer{}), false)
+	gate := make(chan struct{})
+	f := s.Submit(func(ctx context.Context) (int, error) {
+		for i := 1; i <= 3; i++ {
+			Emit(ctx, i)
+			ReportProgress(ctx, i, 4)
+		}
+		<-gate
+		ReportProgress(ctx, 4, 4)
+		return 6, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		r := <-s.Results()
+		if !r.Partial || r.Value != i+1 || r.ID != f.ID {
+			t.Fatalf("got %+v, want partial result %d", r, i+1)
+		}
+	}
+	for f.Progress() != (Progress{3, 4}) {
+		<-f.Updated()
+	}
+	if got := f.Partials(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got partials %v", got)
+	}
+
+	close(gate)
+	r := <-s.Results()
+	if r.Partial || r.Value != 6 {
+		t.Errorf("got %+v, want the final result", r)
+	}
+	if f.Progress() != (Progress{4, 4}) || f.Result() != r {
+		t.Errorf("got progress %v and result %+v", f.Progress(), f.Result())
+	}
+	s.Close()
+	if _, open := <-s.Results(); open {
+		t.Error("results still open after Close")
+	}
+
+	if Emit(context.Background(), 1) || ReportProgress(context.Background(), 1, 1) {
+		t.Error("reported outside of a Stream")
+	}
+}
@@ -0,0 +1,253 @@
+package Workerpool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Tracer follows every request through the Balancer. A request gets a
+// "workerpool.task" span from the moment it comes in to its final result, a
+// child of whatever span its context carries (see WithContext), and under it:
+// 	workerpool.enqueue	waiting on its key, bulkhead, rate limit, tenant or in the shared queue
+// 	workerpool.dispatch	the Strategy picked a worker
+// 	workerpool.queue	waiting in the worker's queue
+// 	workerpool.run		the job running, the job's context carries this span
+// 	workerpool.backoff	waiting to be retried
+// 	workerpool.complete	the Balancer heard back from the worker
+// Spans are started and ended from the Balance loop, apart from the run span
+// which belongs to the worker, so they should be cheap
+type Tracer interface {
+	// Start starts a span as a child of the one in ctx, if any, and
+	// returns a context carrying the new span
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End(end time.Time)
+}
+
+// trace starts the span of a task and its enqueue phase
+func (b *Balancer) trace(t *task, now time.Time) {
+	if b.Tracer == nil {
+		return
+	}
+	t.tracer = b.Tracer
+	t.trace, t.span = b.Tracer.Start(t.ctx, "workerpool.task", now)
+	t.span.SetAttribute("workerpool.id", t.seq)
+	if t.typ != "" {
+		t.span.SetAttribute("workerpool.type", t.typ)
+	}
+	if t.tenant != "" {
+		t.span.SetAttribute("workerpool.tenant", t.tenant)
+	}
+	if t.key != "" {
+		t.span.SetAttribute("workerpool.key", t.key)
+	}
+	t.span.SetAttribute("workerpool.priority", t.priority)
+	b.phase(t, "workerpool.enqueue")
+}
+
+// phase ends the current phase of t and starts the next one, if any
+func (b *Balancer) phase(t *task, name string) {
+	if t.span == nil {
+		return
+	}
+	now := b.clock().Now()
+	if t.phase != nil {
+		t.phase.End(now)
+		t.phase = nil
+	}
+	if name != "" {
+		_, t.phase = t.tracer.Start(t.trace, name, now)
+	}
+}
+
+// event records a span which takes no time
+func (b *Balancer) event(t *task, name string, attrs ...interface{}) {
+	if t.span == nil {
+		return
+	}
+	now := b.clock().Now()
+	_, span := t.tracer.Start(t.trace, name, now)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		span.SetAttribute(attrs[i].(string), attrs[i+1])
+	}
+	span.End(now)
+}
+
+// untrace ends the span of a task which is done
+func (b *Balancer) untrace(t *task, err error) {
+	b.endTrace(t, err, t.attempts)
+}
+
+func (b *Balancer) endTrace(t *task, err error, attempts int) {
+	if t.span == nil {
+		return
+	}
+	b.phase(t, "")
+	if err != nil {
+		t.span.RecordError(err)
+	}
+	t.span.SetAttribute("workerpool.attempts", attempts)
+	t.span.End(b.clock().Now())
+	t.span = nil
+}
+
+// Recorder is a Tracer which keeps the spans in memory, for tests. Its ids
+// look like the W3C trace context ones OpenTelemetry uses
+type Recorder struct {
+	mu 		sync.Mutex
+	spans 	[]*RecordedSpan
+}
+
+// RecordedSpan is a span the Recorder started
+type RecordedSpan struct {
+	TraceID 	string
+	SpanID 		string
+	ParentID 	string	// empty for a root span
+	Name 		string
+	StartTime 	time.Time
+	EndTime 	time.Time	// zero until the span ended
+	Attributes 	map[string]interface{}
+	Errors 		[]error
+
+	rec 		*Recorder
+}
+
+type recordedKey struct{}
+
+// SpanFromContext returns the span the Recorder put in ctx, or nil
+func SpanFromContext(ctx context.Context) *RecordedSpan {
+	s, _ := ctx.Value(recordedKey{}).(*RecordedSpan)
+	return s
+}
+
+func (r *Recorder) Start(ctx context.Context, name string, start time.Time) (context.Context, Span) {
+	s := &RecordedSpan{
+		SpanID: 	randomID(8),
+		Name: 		name,
+		StartTime: 	start,
+		Attributes: make(map[string]interface{}),
+		rec: 		r,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		s.TraceID = randomID(16)
+	}
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return context.WithValue(ctx, recordedKey{}, s), s
+}
+
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.rec.mu.Lock()
+	s.Attributes[key] = value
+	s.rec.mu.Unlock()
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.rec.mu.Lock()
+	s.Errors = append(s.Errors, err)
+	s.rec.mu.Unlock()
+}
+
+func (s *RecordedSpan) End(end time.Time) {
+	s.rec.mu.Lock()
+	s.EndTime = end
+	s.rec.mu.Unlock()
+}
+
+// Spans returns a copy of every span started so far, in the order they
+// were started
+func (r *Recorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]RecordedSpan, len(r.spans))
+	for i, s := range r.spans {
+		spans[i] = *s
+		spans[i].Attributes = make(map[string]interface{}, len(s.Attributes))
+		for k, v := range s.Attributes {
+			spans[i].Attributes[k] = v
+		}
+		spans[i].Errors = append([]error(nil), s.Errors...)
+	}
+	return spans
+}
+
+// Reset forgets every span
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OTel adapts an OpenTelemetry tracer without the package depending on
+// it. OpenTelemetry keeps the current span in the context so every function
+// is a one-liner on top of go.opentelemetry.io/otel, eg:
+// 	Workerpool.OTel{
+// 		StartSpan: func(ctx context.Context, name string, start time.Time) context.Context {
+// 			ctx, _ = tracer.Start(ctx, name, trace.WithTimestamp(start))
+// 			return ctx
+// 		},
+// 		SetAttribute: func(ctx context.Context, key string, value interface{}) {
+// 			trace.SpanFromContext(ctx).SetAttributes(attribute.String(key, fmt.Sprint(value)))
+// 		},
+// 		RecordError: func(ctx context.Context, err error) {
+// 			span := trace.SpanFromContext(ctx)
+// 			span.RecordError(err)
+// 			span.SetStatus(codes.Error, err.Error())
+// 		},
+// 		End: func(ctx context.Context, end time.Time) {
+// 			trace.SpanFromContext(ctx).End(trace.WithTimestamp(end))
+// 		},
+// 	}
+// Any of the functions but StartSpan can be left nil
+type OTel struct {
+	StartSpan 		func(ctx context.Context, name string, start time.Time) context.Context
+	SetAttribute 	func(ctx context.Context, key string, value interface{})
+	RecordError 	func(ctx context.Context, err error)
+	End 			func(ctx context.Context, end time.Time)
+}
+
+// otelSpan is a span of the OTel adapter, it's the context carrying it
+type otelSpan struct {
+	ctx 	context.Context
+	o 		*OTel
+}
+
+func (o OTel) Start(ctx context.Context, name string, start time.Time) (context.Context, Span) {
+	ctx = o.StartSpan(ctx, name, start)
+	return ctx, otelSpan{ctx, &o}
+}
+
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	if s.o.SetAttribute != nil {
+		s.o.SetAttribute(s.ctx, key, value)
+	}
+}
+
+func (s otelSpan) RecordError(err error) {
+	if s.o.RecordError != nil {
+		s.o.RecordError(s.ctx, err)
+	}
+}
+
+func (s otelSpan) End(end time.Time) {
+	if s.o.End != nil {
+		s.o.End(s.ctx, end)
+	}
+}
@@ -0,0 +1,161 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// traced waits for the task span of the only request to end and returns
+// the spans by name
+func traced(t *testing.T, rec *Recorder) map[string][]RecordedSpan {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		byName := make(map[string][]RecordedSpan)
+		for _, s := range rec.Spans() {
+			byName[s.Name] = append(byName[s.Name], s)
+		}
+		if task := byName["workerpool.task"]; len(task) == 1 && !task[0].EndTime.IsZero() {
+			return byName
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("the task span never ended")
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	rec := &Recorder{}
+	requests := startBalancer(t, 2, &Balancer{Tracer: rec})
+
+	ctx, client := rec.Start(context.Background(), "client", time.Now())
+	var inJob *RecordedSpan
+	result := make(chan Result)
+	requests <- NewRequest(func(ctx context.Context) (int, error) {
+		inJob = SpanFromContext(ctx)
+		return 1, nil
+	}, result, WithContext(ctx), WithType("resize"))
+	<-result
+	client.End(time.Now())
+	spans := traced(t, rec)
+
+	var names []string
+	for _, s := range rec.Spans() {
+		names = append(names, s.Name)
+	}
+	want := []string{
+		"client",
+		"workerpool.task",
+		"workerpool.enqueue",
+		"workerpool.dispatch",
+		"workerpool.queue",
+		"workerpool.run",
+		"workerpool.complete",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got spans %q, want %q", names, want)
+	}
+
+	root := spans["client"][0]
+	task := spans["workerpool.task"][0]
+	if task.TraceID != root.TraceID || task.ParentID != root.SpanID {
+		t.Errorf("task span isn't a child of the client span")
+	}
+	if task.Attributes["workerpool.type"] != "resize" || task.Attributes["workerpool.attempts"] != 1 {
+		t.Errorf("got task attributes %v", task.Attributes)
+	}
+	for _, name := range want[2:] {
+		s := spans[name][0]
+		if s.ParentID != task.SpanID || s.TraceID != root.TraceID {
+			t.Errorf("%s isn't a child of the task span", name)
+		}
+		if s.EndTime.IsZero() || s.EndTime.Before(s.StartTime) {
+			t.Errorf("%s runs from %v to %v", name, s.StartTime, s.EndTime)
+		}
+	}
+	if run := spans["workerpool.run"][0]; inJob == nil || inJob.SpanID != run.SpanID {
+		t.Errorf("the job's context doesn't carry the run span")
+	}
+}
+
+func TestTracingRetry(t *testing.T) {
+	rec := &Recorder{}
+	requests := startBalancer(t, 2, &Balancer{Tracer: rec})
+
+	boom := errors.New("boom")
+	result := make(chan Result)
+	requests <- NewRequest(func(context.Context) (int, error) {
+		return 0, boom
+	}, result, WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}))
+	<-result
+	spans := traced(t, rec)
+
+	if len(spans["workerpool.run"]) != 2 || len(spans["workerpool.backoff"]) != 1 ||
+		len(spans["workerpool.enqueue"]) != 2 || len(spans["workerpool.queue"]) != 2 {
+		t.Errorf("got %d runs, %d backoffs, %d enqueues and %d queues", len(spans["workerpool.run"]),
+			len(spans["workerpool.backoff"]), len(spans["workerpool.enqueue"]), len(spans["workerpool.queue"]))
+	}
+	for _, run := range spans["workerpool.run"] {
+		if len(run.Errors) != 1 {
+			t.Errorf("run span has errors %v", run.Errors)
+		}
+	}
+	task := spans["workerpool.task"][0]
+	if len(task.Errors) != 1 || task.Errors[0] != boom || task.Attributes["workerpool.attempts"] != 2 {
+		t.Errorf("got task errors %v and attributes %v", task.Errors, task.Attributes)
+	}
+}
+
+func TestOTel(t *testing.T) {
+	type key struct{}
+	var calls []string
+	o := OTel{
+		StartSpan: func(ctx context.Context, name string, start time.Time) context.Context {
+			calls = append(calls, "start "+name)
+			return context.WithValue(ctx, key{}, name)
+		},
+		SetAttribute: func(ctx context.Context, k string, v interface{}) {
+			calls = append(calls, ctx.Value(key{}).(string)+" "+k)
+		},
+		End: func(ctx context.Context, end time.Time) {
+			calls = append(calls, "end "+ctx.Value(key{}).(string))
+		},
+	}
+	var tracer Tracer = o
+	ctx, span := tracer.Start(context.Background(), "a", time.Now())
+	span.SetAttribute("x", 1)
+	span.RecordError(errors.New("not set, so ignored"))
+	span.End(time.Now())
+	if ctx.Value(key{}) != "a" {
+		t.Error("context doesn't carry the span")
+	}
+	want := []string{"start a", "a x", "end a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got %q, want %q", calls, want)
+	}
+}
+
+func TestTracingTimeout(t *testing.T) {
+	rec := &Recorder{}
+	requests := startBalancer(t, 2, &Balancer{Tracer: rec})
+
+	var inJob *RecordedSpan
+	result := make(chan Result)
+	requests <- NewRequest(func(ctx context.Context) (int, error) {
+		inJob = SpanFromContext(ctx)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return 1, nil
+		}
+	}, result, WithTimeout(20*time.Millisecond))
+	if r := <-result; !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Fatalf("got %+v, want the job to time out", r)
+	}
+	if run := traced(t, rec)["workerpool.run"]; len(run) != 1 || inJob == nil || inJob.SpanID != run[0].SpanID {
+		t.Errorf("the job didn't get its run span")
+	}
+}
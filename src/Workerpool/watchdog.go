@@ -0,0 +1,109 @@
+package Workerpool
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTimeout is the error of a request whose job was still running Grace
+// after its timeout, see WithTimeout. It isn't retried
+var ErrTimeout = errors.New("Workerpool: job timed out")
+
+// A job whose timeout is over has its context canceled, one which ignores it
+// keeps its worker busy for good since Go can't kill a goroutine. The watchdog
+// gives up on such a job once it ran Grace past its timeout: the request fails
+// with ErrTimeout, the worker is poisoned and dropped from the Pool, and a new
+// one takes over its queue. A process worker kills its child process instead,
+// see NewProcessWorker
+
+func (b *Balancer) grace() time.Duration {
+	if b.Grace <= 0 {
+		return time.Second
+	}
+	return b.Grace
+}
+
+// watch arms the watchdog for a task a worker was just handed
+func (b *Balancer) watch(t *task) {
+	if t.timeout <= 0 {
+		return
+	}
+	wait := t.timeout + b.grace()
+	t.deadline = b.clock().Now().Add(wait)
+	if b.alarm.IsZero() || t.deadline.Before(b.alarm) {
+		b.alarm = t.deadline
+		b.bark = b.clock().After(wait)
+	}
+}
+
+// expire gives up on the jobs past their deadline and arms the watchdog for
+// the next one
+func (b *Balancer) expire(now time.Time) {
+	b.alarm, b.bark = time.Time{}, nil
+	// poisoning reorders the heap, so go by a copy
+	for _, w := range append([]*Worker(nil), *b.Pool...) {
+		t := w.running
+		if t == nil || t.deadline.IsZero() {
+			continue
+		}
+		if now.Before(t.deadline) {
+			if b.alarm.IsZero() || t.deadline.Before(b.alarm) {
+				b.alarm = t.deadline
+			}
+			continue
+		}
+		t.deadline = time.Time{}
+		if w.proc != nil && t.remote {
+			// the worker is only waiting on its child, which can be killed.
+			// The job fails with ErrTimeout and comes back through Done
+			b.metrics().TaskTimedOut()
+			w.proc.abort()
+			continue
+		}
+		b.poison(w, t)
+	}
+	if !b.alarm.IsZero() {
+		b.bark = b.clock().After(b.alarm.Sub(now))
+	}
+}
+
+// poison fails the task a worker is stuck on and replaces the worker. The
+// worker still owns the fields it sets on the task, so the Balancer goes by
+// its own
+func (b *Balancer) poison(w *Worker, t *task) {
+	now := b.clock().Now()
+	run := t.timeout + b.grace()
+	b.metrics().TaskTimedOut()
+	b.metrics().TaskDone(now.Sub(t.enqueued)-run, run)
+	b.settle(t, run)
+	b.outcome(t, true, true, false)
+	b.event(t, "workerpool.complete", "workerpool.worker", w.id, "workerpool.timeout", true)
+	if t.claim() && t.result != nil {
+		go func(r Result) {
+			t.result <- r
+		}(Result{Err: ErrTimeout, Attempts: t.tries})
+	}
+	b.endTrace(t, ErrTimeout, t.tries)
+	if t.key != "" {
+		b.unblock(t.key)
+	}
+
+	w.running = nil
+	w.pending -= 1
+	w.poisoned = true
+	w = b.replace(w)
+	if w.pending == 0 {
+		w.idle = now
+	}
+	b.feed(w)
+	b.report(w)
+	b.deal()
+}
+
+// claim tells whether the result of t is still to be sent, and it's the caller
+// who sends it. It's how a worker which hung gets to finish without the
+// caller hearing from it twice
+func (t *task) claim() bool {
+	return atomic.CompareAndSwapInt32(&t.delivered, 0, 1)
+}
@@ -0,0 +1,126 @@
+package Workerpool
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watched gives up on jobs quickly
+func watched(m Metrics) *Balancer {
+	return &Balancer{Timeout: 20 * time.Millisecond, Grace: 20 * time.Millisecond, Metrics: m}
+}
+
+// hang ignores its context until stuck is closed
+func hang(stuck chan struct{}) Job {
+	return func(ctx context.Context) (int, error) {
+		<-stuck
+		return 1, nil
+	}
+}
+
+func poolIDs(b *Balancer) []int {
+	var ids []int
+	for _, w := range b.Inspect().Workers {
+		ids = append(ids, w.ID)
+	}
+	return ids
+}
+
+func TestHungJob(t *testing.T) {
+	c := NewCollector()
+	b := watched(c)
+	requests := startBalancer(t, 1, b)
+	before := poolIDs(b)
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	hung := make(chan Result)
+	requests <- NewRequest(hang(stuck), hung, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	// these queue up behind the hung job and go to the new worker
+	result := make(chan Result, 3)
+	for i := 0; i < 3; i++ {
+		requests <- NewRequest(func(context.Context) (int, error) { return 2, nil }, result)
+	}
+
+	start := time.Now()
+	if r := <-hung; !errors.Is(r.Err, ErrTimeout) || r.Attempts != 1 {
+		t.Fatalf("got %+v, want ErrTimeout", r)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("gave up on the job after %v", d)
+	}
+	for i := 0; i < 3; i++ {
+		if r := <-result; r.Err != nil || r.Value != 2 {
+			t.Errorf("got %+v after the worker was replaced", r)
+		}
+	}
+
+	after := poolIDs(b)
+	if len(after) != 1 || after[0] == before[0] {
+		t.Errorf("got workers %v after %v hung, want a new one", after, before)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "workerpool_tasks_timed_out_total 1\n") {
+		t.Errorf("metrics are missing the timeout:\n%s", body)
+	}
+}
+
+func TestHungJobFinishes(t *testing.T) {
+	b := watched(nil)
+	requests := startBalancer(t, 1, b)
+
+	stuck := make(chan struct{})
+	hung := make(chan Result, 2)
+	requests <- NewRequest(hang(stuck), hung)
+	if r := <-hung; !errors.Is(r.Err, ErrTimeout) {
+		t.Fatalf("got %+v, want ErrTimeout", r)
+	}
+
+	// the poisoned worker finishing after all is ignored
+	close(stuck)
+	result := make(chan Result)
+	requests <- NewRequest(func(context.Context) (int, error) { return 2, nil }, result)
+	if r := <-result; r.Err != nil {
+		t.Errorf("got %+v", r)
+	}
+	select {
+	case r := <-hung:
+		t.Errorf("got a second result %+v", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if ids := poolIDs(b); len(ids) != 1 {
+		t.Errorf("got %d workers, want 1", len(ids))
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	b := watched(nil)
+	requests := startBalancer(t, 1, b)
+	before := poolIDs(b)
+
+	// a job which gives up once its context is done keeps its worker
+	result := make(chan Result)
+	requests <- NewRequest(func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, result, WithTimeout(5*time.Millisecond))
+	if r := <-result; !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Errorf("got %+v, want the deadline", r)
+	}
+	requests <- NewRequest(func(ctx context.Context) (int, error) {
+		time.Sleep(30 * time.Millisecond)
+		return 1, ctx.Err()
+	}, result, WithTimeout(time.Second))
+	if r := <-result; r.Err != nil {
+		t.Errorf("got %+v within the timeout", r)
+	}
+	if after := poolIDs(b); after[0] != before[0] {
+		t.Errorf("got workers %v, want %v", after, before)
+	}
+}
@@ -1,18 +1,150 @@
 package Workerpool 
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
 type Worker struct {
-	requests 	chan Request	// All the pending requests(work to do ..)
+	requests 	chan *task		// The request to work on, handed over by the Balancer
+	queue 		queue			// All the pending requests(work to do ..)
+	running 	*task			// the request being worked on, nil when idle
 	pending 	int				// count of remaining tasks
 	index 		int				// index in the heap
+	id 			int				// unique and stable, unlike the index
+	quit 		chan struct{}	// closed when the worker is retired
+	idle 		time.Time		// when the worker last ran out of work
+	capacity 	int				// relative speed of the worker, used by Weighted
+	avg 		time.Duration	// moving average of the job durations
+	hooks 		*Hooks			// set before the worker gets its first job
+	state 		interface{}		// what OnStart returned
+	started 	bool
+	broken 		error			// why OnStart failed
+	proc 		*process		// the child running the tasks, see NewProcessWorker
+	poisoned 	bool			// stuck on a job past its timeout, kept by the Balancer
+}
+
+var workerIDs int64
+
+// newWorker creates a worker and starts it, the worker reports to done
+// every time it finishes a request
+func newWorker(done chan *Worker) *Worker {
+	w := makeWorker()
+	go w.Work(done)
+	return w
+}
+
+// makeWorker creates a worker without starting it, whoever takes the tasks
+// off its requests channel runs them
+func makeWorker() *Worker {
+	return &Worker{
+		requests: 	make(chan *task, 1),
+		id: 		int(atomic.AddInt64(&workerIDs, 1)),
+		quit: 		make(chan struct{}),
+		idle: 		time.Now(),
+		capacity: 	1,
+	}
 }
 
 // Worker performs the work to be done
 func (w *Worker) Work(done chan *Worker) {
+	defer w.stop()
+	defer func() {
+		if w.proc != nil {
+			w.proc.close()
+		}
+	}()
 	for {
 		select {
-		case req := <-w.requests:
-			req.result <- req.job()
-			done <- w
+		case t := <-w.requests:
+			w.start()
+			t.run(w)
+			t.unhealthy = !w.healthy()
+			// the Balancer moved on from a worker it poisoned
+			select {
+			case done <- w:
+			case <-w.quit:
+				return
+			}
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// observe folds the duration of the last job into the moving average, it's
+// called by the Balancer once the worker reported the job as done
+func (w *Worker) observe(d time.Duration) {
+	if w.avg == 0 {
+		w.avg = d
+		return
+	}
+	w.avg += (d - w.avg) / 8
+}
+
+// run runs the job and sends the result, unless the job failed and the retry
+// policy says to try again in which case the Balancer takes care of it
+func (t *task) run(w *Worker) {
+	t.again, t.panicked, t.elapsed, t.ran, t.failed = false, false, 0, false, false
+	if err := t.ctx.Err(); err != nil {
+		t.err = err
+		if t.claim() && t.result != nil {
+			t.result <- Result{Err: err, Attempts: t.attempts}
 		}
+		return
 	}
-}
\ No newline at end of file
+
+	t.attempts++
+	ctx, span := t.ctx, Span(nil)
+	if t.tracer != nil {
+		// the trace comes from t.ctx, the timeout goes on top of it
+		ctx, span = t.tracer.Start(t.trace, "workerpool.run", time.Now())
+		span.SetAttribute("workerpool.attempt", t.attempts)
+		span.SetAttribute("workerpool.worker", w.id)
+	}
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	v, err := t.call(w, ctx)
+	t.elapsed = time.Since(start)
+	t.ran, t.failed, t.err = true, err != nil, err
+	if span != nil {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End(time.Now())
+	}
+
+	// a job the watchdog gave up on isn't retried, wherever it ran
+	t.again = err != nil && t.ctx.Err() == nil && !errors.Is(err, ErrTimeout) && t.retry.retry(t.attempts, err)
+	if !t.again && t.claim() && t.result != nil {
+		t.result <- Result{Value: v, Err: err, Attempts: t.attempts}
+	}
+}
+
+// call calls the job, a job which panics doesn't take the worker down
+// with it but fails with an error instead
+func (t *task) call(w *Worker, ctx context.Context) (v int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.panicked = true
+			v, err = 0, fmt.Errorf("Workerpool: job panicked: %v", r)
+		}
+	}()
+	if w.broken != nil {
+		return 0, w.broken
+	}
+	if w.proc != nil && t.remote {
+		return w.proc.call(ctx, t.typ, t.payload)
+	}
+	if w.hooks == nil {
+		return t.job(ctx)
+	}
+	return t.job(context.WithValue(ctx, stateKey{}, w.state))
+}
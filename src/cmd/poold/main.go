@@ -0,0 +1,57 @@
+// poold runs a worker pool and serves it over HTTP, see Workerpool.Server
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"../../Workerpool"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:7070", "address to listen on")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of workers")
+	retain := flag.Duration("retain", time.Hour, "how long finished tasks can be looked up")
+	flag.Parse()
+
+	// the task types anybody can submit, payloads are plain strings
+	registry := Workerpool.NewRegistry()
+	registry.Register("sleep", func(ctx context.Context, payload []byte) (int, error) {
+		d, err := time.ParseDuration(string(payload))
+		if err != nil {
+			return 0, err
+		}
+		select {
+		case <-time.After(d):
+			return int(d / time.Millisecond), nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	registry.Register("atoi", func(ctx context.Context, payload []byte) (int, error) {
+		return strconv.Atoi(string(payload))
+	})
+
+	metrics := Workerpool.NewCollector()
+	requests := make(chan Workerpool.Request)
+	done := make(chan *Workerpool.Worker)
+	balancer := &Workerpool.Balancer{
+		Pool: 		Workerpool.New(*workers, done),
+		Done: 		done,
+		Metrics: 	metrics,
+	}
+	go balancer.Balance(requests)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := Workerpool.NewServer(registry, requests)
+	server.Retain = *retain
+	mux.Handle("/", server)
+	log.Printf("poold listening on %s with %d workers", *addr, *workers)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"expvar"
 	"fmt"
+	"net/http"
 	"runtime"
+	"time"
 	"./Workerpool"
 )
 
@@ -13,7 +16,24 @@ func main() {
 	requests := make(chan Workerpool.Request)
 	done := make(chan *Workerpool.Worker)
 	pool := Workerpool.New(available_cpus, done)
-	balancer := &Workerpool.Balancer{pool, done}
+
+	// metrics are at /metrics for prometheus and /debug/vars for expvar
+	metrics := Workerpool.NewCollector()
+	expvar.Publish("workerpool", metrics)
+	http.Handle("/metrics", metrics)
+	go http.ListenAndServe("localhost:8080", nil)
+
+	balancer := &Workerpool.Balancer{
+		Pool: 	pool,
+		Done: 	done,
+		Metrics: metrics,
+		Scaler: &Workerpool.Scaler{
+			Min: 			1,
+			Max: 			2 * available_cpus,
+			Threshold: 		2,
+			IdleTimeout: 	10 * time.Second,
+		},
+	}
 
 	go balancer.Balance(requests)
 	Workerpool.Requester(requests)